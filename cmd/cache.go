@@ -0,0 +1,71 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/kubecfg/kubecfg/pkg/kubecfg"
+)
+
+const (
+	flagOlderThan = "older-than"
+)
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cachePruneCmd.PersistentFlags().Duration(flagOlderThan, 0, "Only remove entries fetched longer than this ago (e.g. 720h). By default the whole cache is removed.")
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of remote imports",
+	Args:  cobra.NoArgs,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the remote imports stored in the cache",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := kubecfg.CacheListCmd{
+			Dir: viper.GetString(flagCacheDir),
+		}
+		return c.Run(cmd.OutOrStdout())
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove remote imports from the cache",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		c := kubecfg.CachePruneCmd{
+			Dir: viper.GetString(flagCacheDir),
+		}
+
+		c.OlderThan, err = cmd.Flags().GetDuration(flagOlderThan)
+		if err != nil {
+			return err
+		}
+
+		return c.Run(cmd.OutOrStdout())
+	},
+}
@@ -26,6 +26,7 @@ const (
 	flagOverlay         = "overlay" // deprecated
 	flagOverlayCode     = "overlay-code"
 	flagOverlayCodeFile = "overlay-code-file"
+	flagOCIEntrypoint   = "oci-entrypoint"
 )
 
 type commonFlagOpts struct {
@@ -61,4 +62,5 @@ func addCommonEvalFlags(cmd *cobra.Command, opt ...commonEvalFlagOpt) {
 	flags.String(flagOverlayCode, "", "Inline Jsonnet code to compose to each of the input files")
 	flags.String(flagOverlayCodeFile, "", "Jsonnet file to compose to each of the input files")
 	cmd.MarkFlagsMutuallyExclusive(flagOverlay, flagOverlayCode, flagOverlayCodeFile)
+	flags.String(flagOCIEntrypoint, "", "Named entrypoint of the OCI bundles given as input, unless selected with oci://repo:tag#name")
 }
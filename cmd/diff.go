@@ -43,7 +43,7 @@ var diffCmd = &cobra.Command{
 		flags := cmd.Flags()
 		var err error
 
-		c := kubecfg.DiffCmd{}
+		c := kubecfg.DiffCmd{Redactor: redactor}
 
 		c.DiffStrategy, err = flags.GetString(flagDiffStrategy)
 		if err != nil {
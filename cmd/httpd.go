@@ -17,21 +17,26 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	jsonnet "github.com/google/go-jsonnet"
 	"github.com/kubecfg/kubecfg/pkg/kubecfg"
+	"github.com/kubecfg/kubecfg/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	flagListenAddr = "listen-addr"
+	flagListenAddr          = "listen-addr"
+	flagUnrestrictedImports = "unrestricted-imports"
 )
 
 func init() {
 	cmd := httpdCmd
 	RootCmd.AddCommand(cmd)
 	cmd.PersistentFlags().StringP(flagListenAddr, "l", ":8080", "address:port to listen")
+	cmd.PersistentFlags().Bool(flagUnrestrictedImports, false, "Don't restrict the import schemes and roots that the import policy leaves unset. By default only local files in the current directory, the directories of the served files and the library search paths can be imported.")
 }
 
 var httpdCmd = &cobra.Command{
@@ -53,14 +58,83 @@ var httpdCmd = &cobra.Command{
 			return err
 		}
 
-		mkVM := func() (*jsonnet.VM, error) {
-			return JsonnetVM(cmd)
-		}
-
 		if len(args) < 1 {
 			return fmt.Errorf("jsonnet filename required")
 		}
 
+		policy, err := httpdImportPolicy(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		mkVM := func() (*jsonnet.VM, error) {
+			return JsonnetVM(cmd, kubecfg.WithImportPolicy(policy))
+		}
+
 		return c.Run(cmd.Context(), mkVM, args)
 	},
 }
+
+// httpdImportPolicy returns the configured import policy, where the local roots and the schemes
+// that are not configured are restricted like defaultHttpdImportPolicy does, unless --unrestricted-imports is given.
+func httpdImportPolicy(cmd *cobra.Command, paths []string) (*utils.ImportPolicy, error) {
+	policy, err := importPolicy(cmd)
+	if err != nil {
+		return nil, err
+	}
+	unrestricted, err := cmd.Flags().GetBool(flagUnrestrictedImports)
+	if err != nil {
+		return nil, err
+	}
+	if unrestricted {
+		return policy, nil
+	}
+	defaults, err := defaultHttpdImportPolicy(cmd, paths)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return defaults, nil
+	}
+	if len(policy.AllowedRoots) == 0 {
+		policy.AllowedRoots = defaults.AllowedRoots
+	}
+	if len(policy.AllowedSchemes) == 0 {
+		policy.AllowedSchemes = defaults.AllowedSchemes
+	}
+	return policy, nil
+}
+
+// defaultHttpdImportPolicy allows importing only local files from the current directory, the directories
+// of the served files and the library search paths, since httpd evaluates code on behalf of remote clients.
+func defaultHttpdImportPolicy(cmd *cobra.Command, paths []string) (*utils.ImportPolicy, error) {
+	jpath, err := cmd.Flags().GetStringArray(flagJpath)
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	roots := []string{cwd}
+	if vendorDir, found, err := utils.FindVendorDir(cwd); err != nil {
+		return nil, err
+	} else if found {
+		roots = append(roots, vendorDir)
+	}
+	for _, p := range paths {
+		roots = append(roots, filepath.Dir(p))
+	}
+	roots = append(roots, filepath.SplitList(os.Getenv("KUBECFG_JPATH"))...)
+	roots = append(roots, jpath...)
+
+	policy := &utils.ImportPolicy{AllowedSchemes: []string{"file"}}
+	for _, r := range roots {
+		abs, err := filepath.Abs(r)
+		if err != nil {
+			return nil, err
+		}
+		policy.AllowedRoots = append(policy.AllowedRoots, abs)
+	}
+	return policy, nil
+}
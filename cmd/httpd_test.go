@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestHttpdImportPolicy(t *testing.T) {
+	resetFlags()
+	t.Cleanup(resetFlags)
+
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "sync.jsonnet")
+	if err := httpdCmd.ParseFlags([]string{"--import-max-size", "10Mi"}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := httpdImportPolicy(httpdCmd, []string{hook})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := policy.MaxDownloadSize, int64(10<<20); got != want {
+		t.Errorf("max download size: got %d, want %d", got, want)
+	}
+
+	for _, tc := range []struct {
+		url     string
+		allowed bool
+	}{
+		{"file://" + filepath.ToSlash(filepath.Join(dir, "lib.libsonnet")), true},
+		{"file:///etc/passwd", false},
+		{"https://example.com/lib.libsonnet", false},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := policy.Check(u); (err == nil) != tc.allowed {
+			t.Errorf("%s: want allowed %v, got error: %v", tc.url, tc.allowed, err)
+		}
+	}
+
+	if err := httpdCmd.ParseFlags([]string{"--" + flagUnrestrictedImports}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err = httpdImportPolicy(httpdCmd, []string{hook})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.AllowedRoots) != 0 || len(policy.AllowedSchemes) != 0 {
+		t.Errorf("unrestricted imports: got %+v", policy)
+	}
+}
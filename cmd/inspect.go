@@ -0,0 +1,50 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/kubecfg/kubecfg/pkg/kubecfg"
+)
+
+func init() {
+	cmd := inspectCmd
+	RootCmd.AddCommand(cmd)
+	cmd.PersistentFlags().StringP(flagFormat, "o", "text", "Output format.  Supported values are: text, json")
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [flags] oci_package",
+	Short: "Show the manifest, configuration and files of an OCI bundle",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		c := kubecfg.InspectCmd{}
+
+		c.Format, err = cmd.Flags().GetString(flagFormat)
+		if err != nil {
+			return err
+		}
+
+		c.ImporterOpts, err = importerOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		return c.Run(cmd.Context(), args[0], cmd.OutOrStdout())
+	},
+}
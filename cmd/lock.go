@@ -0,0 +1,55 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubecfg/kubecfg/pkg/kubecfg"
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+func init() {
+	RootCmd.AddCommand(lockCmd)
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [flags] jsonnet_file...",
+	Short: "Pin the content of all remote imports in the lockfile",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := lockfilePath()
+		if path == "" {
+			return fmt.Errorf("--%s must not be empty", flagLockFile)
+		}
+		lockfile, err := utils.LoadLockfile(path, utils.LockRegenerate)
+		if err != nil {
+			return err
+		}
+		// JsonnetVM picks up the regenerated lockfile.
+		cmdLockfile = lockfile
+
+		vm, err := JsonnetVM(cmd)
+		if err != nil {
+			return err
+		}
+
+		c := kubecfg.LockCmd{Lockfile: lockfile}
+		return c.Run(vm, args, cmd.OutOrStdout())
+	},
+}
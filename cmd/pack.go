@@ -28,6 +28,8 @@ const (
 	flagInsecureRegistry = "insecure-registry"
 	flagDocsTarFile      = "docs-tar-file"
 	flagAnnotations      = "annotation"
+	flagSignKey          = "sign-key"
+	flagEntrypoint       = "entrypoint"
 )
 
 func init() {
@@ -37,6 +39,9 @@ func init() {
 	cmd.PersistentFlags().Bool(flagInsecureRegistry, false, "Use HTTP instead of HTTPS to access the OCI registry")
 	cmd.PersistentFlags().String(flagDocsTarFile, "", "Optional tar.gz file containing a documentation bundle")
 	cmd.PersistentFlags().StringSlice(flagAnnotations, nil, "Annotations to add to the OCI image manifest")
+	cmd.PersistentFlags().String(flagSignKey, "", "Sign the pushed bundle with this private key (cosign compatible; encrypted keys are decrypted with $COSIGN_PASSWORD)")
+	cmd.MarkPersistentFlagFilename(flagSignKey)
+	cmd.PersistentFlags().StringArray(flagEntrypoint, nil, "Additional named entrypoint, as name=file. Can be repeated")
 }
 
 var packCmd = &cobra.Command{
@@ -77,6 +82,16 @@ var packCmd = &cobra.Command{
 			return err
 		}
 
-		return c.Run(cmd.Context(), vm, args[0], args[1])
+		c.SignKey, err = flags.GetString(flagSignKey)
+		if err != nil {
+			return err
+		}
+
+		c.Entrypoints, err = flags.GetStringArray(flagEntrypoint)
+		if err != nil {
+			return err
+		}
+
+		return c.Run(cmd.Context(), vm, args[0], args[1], cmd.OutOrStdout())
 	},
 }
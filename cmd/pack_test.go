@@ -13,8 +13,11 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/opencontainers/go-digest"
 )
 
 const (
@@ -112,6 +115,49 @@ func TestPack(t *testing.T) {
 	verifyBodyTarball(t, f)
 }
 
+func TestPackReproducible(t *testing.T) {
+	dir := t.TempDir()
+	var digests []string
+	for i, name := range []string{"a.tar.gz", "b.tar.gz"} {
+		if i > 0 {
+			// make sure the source files don't have the same timestamps
+			time.Sleep(10 * time.Millisecond)
+		}
+		output := filepath.Join(dir, name)
+		out := cmdOutput(t, []string{"--alpha", "pack", testRef, prepareTestData(t), "--output", output})
+
+		b, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.TrimSpace(out), digest.FromBytes(b).String(); got != want {
+			t.Errorf("printed digest %q, want %q", got, want)
+		}
+		digests = append(digests, digest.FromBytes(b).String())
+
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.ModTime.Unix() != 0 || hdr.Uid != 0 || hdr.Gid != 0 || hdr.Mode != 0644 {
+				t.Errorf("%s: non reproducible header %+v", hdr.Name, hdr)
+			}
+		}
+	}
+	if digests[0] != digests[1] {
+		t.Errorf("packing twice produced different digests: %v", digests)
+	}
+}
+
 func TestPush(t *testing.T) {
 	// This is basically a snapshot test that breaks as soon as the OCI client does something different.
 	// The OCI spec is strict enough so that this shouldn't be a problem.
@@ -177,7 +223,7 @@ func TestPush(t *testing.T) {
 
 	// these digests have been observed by running the test failing and obverving the logs
 	const (
-		blobDigest = "sha256:90847d8bd5ca990ebf777bf131130cdcf695ace2f8bb59dee0571129af855f00"
+		blobDigest = "sha256:f896213d6e39d0731c818699403cf16bb142d82e2c6b5e213afce35eb4e99d2f"
 		confDigest = "sha256:d5e1762d319a9de4f26f64420d485611ce6f1bdd4418e4d37d0b388ebc996fc0"
 	)
 	getBlob := func(key string) []byte {
@@ -195,3 +241,130 @@ func TestPush(t *testing.T) {
 	}
 
 }
+
+func TestPackOCILayout(t *testing.T) {
+	priv, pub := writeKeyPair(t)
+	dir := t.TempDir()
+
+	for _, layout := range []string{filepath.Join(dir, "layout"), filepath.Join(dir, "layout.tar")} {
+		cmdOutput(t, []string{"--alpha", "pack", "oci-layout://" + layout + ":v1", prepareTestData(t), "--sign-key", priv})
+
+		main := filepath.Join(dir, "main.jsonnet")
+		if err := os.WriteFile(main, []byte("import 'oci-layout://"+layout+":v1'"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out := cmdOutput(t, []string{"show", "--alpha", "--verify-bundles", "--trusted-key", pub, "-o", "json", main})
+		if !strings.Contains(out, `"name": "demo"`) {
+			t.Errorf("%s: unexpected output:\n%s", layout, out)
+		}
+
+		out = cmdOutput(t, []string{"inspect", "oci-layout://" + layout + ":v1"})
+		if !strings.Contains(out, testBodyFilename) {
+			t.Errorf("%s: inspect output doesn't list %s:\n%s", layout, testBodyFilename, out)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "layout", "index.json")); err != nil {
+		t.Error(err)
+	}
+
+	// Layouts are pinned in the lockfile and subject to the maximum download size.
+	main := filepath.Join(dir, "main.jsonnet")
+	lock := filepath.Join(dir, "kubecfg.lock")
+	cmdOutput(t, []string{"show", "--alpha", "--lock-file", lock, "--update-lock", main})
+	b, err := os.ReadFile(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "oci-layout://") {
+		t.Errorf("layout import not pinned:\n%s", b)
+	}
+	if err := cmdError(t, []string{"show", "--alpha", "--lock-file", lock, "--import-max-size", "16", main}); err == nil || !strings.Contains(err.Error(), "maximum download size") {
+		t.Errorf("expected maximum download size error, got: %v", err)
+	}
+}
+
+func TestPackVendorsRemoteImports(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.FS(fstest.MapFS{
+		"lib/main.libsonnet": {Data: []byte("{ name: import 'name.libsonnet', data: importstr 'data.txt' }")},
+		"lib/name.libsonnet": {Data: []byte("'remote'")},
+		"lib/data.txt":       {Data: []byte("hello")},
+	})))
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"src/files/local.txt": "local",
+		"src/files/blob.bin":  "\x00\x01",
+		"src/main.jsonnet": `local lib = import '` + srv.URL + `/lib/main.libsonnet';
+{
+  apiVersion: 'v1',
+  kind: 'ConfigMap',
+  metadata: { name: lib.name },
+  data: { remote: lib.data, 'local': importstr 'files/local.txt', bin: std.base64(importbin 'files/blob.bin') },
+}`,
+		"consumer.jsonnet": "import 'oci-layout://" + filepath.Join(dir, "layout") + ":v1'",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmdOutput(t, []string{"--alpha", "pack", "--cache-dir=", "oci-layout://" + filepath.Join(dir, "layout") + ":v1", filepath.Join(dir, "src", "main.jsonnet")})
+	srv.Close()
+
+	out := cmdOutput(t, []string{"inspect", "oci-layout://" + filepath.Join(dir, "layout") + ":v1"})
+	if !strings.Contains(out, "_vendor/http/") {
+		t.Errorf("remote imports are not vendored:\n%s", out)
+	}
+
+	out = cmdOutput(t, []string{"show", "--alpha", "--cache-dir=", "-o", "json", filepath.Join(dir, "consumer.jsonnet")})
+	for _, want := range []string{`"name": "remote"`, `"remote": "hello"`, `"local": "local"`, `"bin": "AAE="`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestPackNamedEntrypoints(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"src/main.jsonnet":      "{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'default' } }",
+		"src/envs/prod.jsonnet": "local base = import '../main.jsonnet';\nfunction(region, replicas=1) base { metadata: { name: 'prod-' + region } }",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	layout := "oci-layout://" + filepath.Join(dir, "layout") + ":v1"
+	cmdOutput(t, []string{"--alpha", "pack", layout, filepath.Join(dir, "src", "main.jsonnet"), "--entrypoint", "prod=" + filepath.Join(dir, "src", "envs", "prod.jsonnet")})
+
+	out := cmdOutput(t, []string{"show", "--alpha", "-o", "json", layout})
+	if !strings.Contains(out, `"name": "default"`) {
+		t.Errorf("unexpected default entrypoint output:\n%s", out)
+	}
+	for _, args := range [][]string{
+		{layout + "#prod"},
+		{"--oci-entrypoint", "prod", layout},
+	} {
+		out := cmdOutput(t, append([]string{"show", "--alpha", "-o", "json", "-A", "region=eu"}, args...))
+		if !strings.Contains(out, `"name": "prod-eu"`) {
+			t.Errorf("%q: unexpected output:\n%s", args, out)
+		}
+	}
+
+	err := cmdError(t, []string{"show", "--alpha", layout + "#staging"})
+	if err == nil || !strings.Contains(err.Error(), `no entrypoint "staging"`) {
+		t.Errorf("expected unknown entrypoint error, got: %v", err)
+	}
+
+	out = cmdOutput(t, []string{"inspect", layout})
+	if want := "prod: envs/prod.jsonnet (TLAs: region, replicas (optional))"; !strings.Contains(out, want) {
+		t.Errorf("inspect output doesn't contain %q:\n%s", want, out)
+	}
+}
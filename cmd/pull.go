@@ -0,0 +1,61 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/kubecfg/kubecfg/pkg/kubecfg"
+)
+
+const (
+	flagDocsDir = "docs-dir"
+)
+
+func init() {
+	cmd := pullCmd
+	RootCmd.AddCommand(cmd)
+	cmd.PersistentFlags().String(flagDocsDir, "", "Directory where the documentation layer is extracted (default <dir>/_docs)")
+	cmd.MarkPersistentFlagDirname(flagDocsDir)
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull [flags] oci_package [dir]",
+	Short: "Extract the files of an OCI bundle into a directory",
+	Long: `Extract the files of an OCI bundle into a directory (the current directory by default),
+so that the bundle can be audited or edited locally. The documentation layer, if any,
+is extracted into the _docs subdirectory unless --docs-dir is given.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		c := kubecfg.PullCmd{Dir: "."}
+		if len(args) > 1 {
+			c.Dir = args[1]
+		}
+
+		c.DocsDir, err = cmd.Flags().GetString(flagDocsDir)
+		if err != nil {
+			return err
+		}
+
+		c.ImporterOpts, err = importerOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		return c.Run(cmd.Context(), args[0], cmd.OutOrStdout())
+	},
+}
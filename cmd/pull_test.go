@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func writeDocsTarball(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docs.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	const readme = "# demo"
+	if err := tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0644, Size: int64(len(readme)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(readme))
+	tw.Close()
+	gw.Close()
+	return path
+}
+
+func TestInspectAndPull(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/demo:v1"
+	cmdOutput(t, []string{"--alpha", "pack", ref, prepareTestData(t), "--insecure-registry", "--docs-tar-file", writeDocsTarball(t)})
+
+	out := cmdOutput(t, []string{"inspect", "--cache-dir=", "-o", "json", "oci://" + ref})
+	var info struct {
+		Entrypoint string   `json:"entrypoint"`
+		Digest     string   `json:"digest"`
+		Files      []string `json:"files"`
+		Docs       []string `json:"docs"`
+	}
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if got, want := info.Entrypoint, testRootFilename; got != want {
+		t.Errorf("got entrypoint %q, want %q", got, want)
+	}
+	if got, want := strings.Join(info.Files, ","), testBodyFilename+","+testRootFilename; got != want {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+	if got, want := strings.Join(info.Docs, ","), "README.md"; got != want {
+		t.Errorf("got docs %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(info.Digest, "sha256:") {
+		t.Errorf("unexpected digest %q", info.Digest)
+	}
+
+	dir := t.TempDir()
+	cmdOutput(t, []string{"pull", "--cache-dir=", ref, dir})
+	for path, want := range map[string]string{
+		testRootFilename:  testRoot,
+		testBodyFilename:  testBody,
+		"_docs/README.md": "# demo",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(b); got != want {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+}
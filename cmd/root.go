@@ -17,6 +17,7 @@ package cmd
 
 import (
 	"bytes"
+	"crypto"
 	"encoding/json"
 	goflag "flag"
 	"fmt"
@@ -26,12 +27,15 @@ import (
 	"path/filepath"
 	"strings"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	jsonnet "github.com/google/go-jsonnet"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
@@ -63,8 +67,42 @@ const (
 	flagResolver    = "resolve-images"
 	flagResolvFail  = "resolve-images-error"
 	flagQPSLimit    = "qps-limit"
+	flagCacheDir    = "cache-dir"
+	flagOffline     = "offline"
+	flagLockFile    = "lock-file"
+	flagUpdateLock  = "update-lock"
+	flagPinImages   = "pin-images"
+	flagConfigHash  = "config-hash"
+	flagPlatform    = "platform"
+
+	flagImportAllowRoot   = "import-allow-root"
+	flagImportAllowHost   = "import-allow-host"
+	flagImportAllowScheme = "import-allow-scheme"
+	flagImportMaxSize     = "import-max-size"
+	flagVerifyBundles     = "verify-bundles"
+	flagTrustedKey        = "trusted-key"
+	flagSopsAgeKeyFile    = "sops-age-key-file"
+	flagSopsPGPKeyFile    = "sops-pgp-key-file"
 )
 
+// projectKeyHTTPAuth is the project file key holding the credentials for HTTP(S) imports.
+const projectKeyHTTPAuth = "http-auth"
+
+// projectKeyImageRewrite is the project file key holding the rules of the mirror image resolver.
+const projectKeyImageRewrite = "image-rewrite"
+
+// projectKeySensitivePaths is the project file key holding the paths of the object
+// fields to redact, in addition to the data of Secrets.
+const projectKeySensitivePaths = "sensitive-paths"
+
+// redactor hides the sensitive values of the objects read by kubecfg. It scrubs
+// the values it has seen from all the log messages, errors included.
+var redactor = utils.NewRedactor(nil)
+
+// projectFileName is the name of the optional file providing per-project defaults for
+// the command line flags. It's looked up in the current directory and its parents.
+const projectFileName = ".kubecfg.yaml"
+
 var clientConfig clientcmd.ClientConfig
 var overrides clientcmd.ConfigOverrides
 
@@ -89,7 +127,32 @@ func init() {
 	RootCmd.PersistentFlags().StringArray(flagTLACodeFile, nil, "Read top level arguments with values supplied as Jsonnet code from files")
 	RootCmd.MarkPersistentFlagFilename(flagTLACodeFile)
 	RootCmd.PersistentFlags().String(flagResolver, kubecfg.NoopResolver.String(), fmt.Sprintf("Change implementation of resolveImage native function. One of: %s", strings.Join(kubecfg.AvailableResolverTypes(), ", ")))
+	RootCmd.PersistentFlags().String(flagPlatform, "", "Resolve multi-platform images to the digest of the manifest of this platform (e.g. linux/arm64) rather than of their index")
 	RootCmd.PersistentFlags().String(flagResolvFail, kubecfg.WarnResolverError.String(), fmt.Sprintf("Action when resolveImage fails. One of: %s", strings.Join(kubecfg.AvailableResolverFailureAction(), ", ")))
+	defaultCacheDir, err := utils.DefaultCacheDir()
+	if err != nil {
+		log.Debugf("cannot determine default cache directory: %v", err)
+	}
+	RootCmd.PersistentFlags().String(flagCacheDir, defaultCacheDir, "Directory where remote imports are cached. An empty value disables the cache.")
+	RootCmd.MarkPersistentFlagDirname(flagCacheDir)
+	RootCmd.PersistentFlags().Bool(flagOffline, false, "Never access the network to fetch remote imports; use only the ones in the cache")
+	RootCmd.PersistentFlags().String(flagLockFile, utils.DefaultLockfileName, "Lockfile pinning the content of remote imports. Checked only if it exists.")
+	RootCmd.MarkPersistentFlagFilename(flagLockFile)
+	RootCmd.PersistentFlags().Bool(flagUpdateLock, false, "Pin the current content of remote imports in the lockfile instead of checking it")
+	RootCmd.PersistentFlags().Bool(flagPinImages, false, "Rewrite the image of every container to image@digest, using the digests pinned in the lockfile")
+	RootCmd.PersistentFlags().String(flagConfigHash, "", "Roll out workloads when the ConfigMaps and Secrets they use change: 'annotation' annotates their pod templates with a hash of the content, 'suffix' appends the hash to the names of the ConfigMaps and Secrets")
+	RootCmd.PersistentFlags().StringArray(flagImportAllowRoot, nil, "Only allow importing local files from these directories. May be repeated.")
+	RootCmd.MarkPersistentFlagDirname(flagImportAllowRoot)
+	RootCmd.PersistentFlags().StringArray(flagImportAllowHost, nil, "Only allow remote imports from these hosts; '*.example.com' matches all subdomains. May be repeated.")
+	RootCmd.PersistentFlags().StringArray(flagImportAllowScheme, nil, "Only allow imports with these URL schemes (e.g. file, https, oci). May be repeated.")
+	RootCmd.PersistentFlags().String(flagImportMaxSize, "", "Maximum size of a single imported file (e.g. 10Mi)")
+	RootCmd.PersistentFlags().Bool(flagVerifyBundles, false, "Refuse to import OCI bundles not signed by one of the --trusted-key keys")
+	RootCmd.PersistentFlags().StringArray(flagTrustedKey, nil, "Public key (e.g. cosign.pub) trusted to sign OCI bundles. May be repeated.")
+	RootCmd.MarkPersistentFlagFilename(flagTrustedKey)
+	RootCmd.PersistentFlags().StringArray(flagSopsAgeKeyFile, nil, "File with the age identities decrypting sops files, instead of $SOPS_AGE_KEY_FILE. May be repeated.")
+	RootCmd.MarkPersistentFlagFilename(flagSopsAgeKeyFile)
+	RootCmd.PersistentFlags().StringArray(flagSopsPGPKeyFile, nil, "File with the PGP secret keys decrypting sops files. May be repeated.")
+	RootCmd.MarkPersistentFlagFilename(flagSopsPGPKeyFile)
 	RootCmd.PersistentFlags().Float32(flagQPSLimit, 0, "Override k8s REST client-side rate limiting; library default is 5 QPS; a negative value disables.")
 
 	// The "usual" clientcmd/kubectl flags
@@ -102,6 +165,8 @@ func init() {
 	clientConfig = clientcmd.NewInteractiveDeferredLoadingClientConfig(loadingRules, &overrides, os.Stdin)
 
 	viper.BindPFlags(RootCmd.PersistentFlags())
+
+	log.AddHook(redactionHook{})
 }
 
 // RootCmd is the root of cobra subcommand tree
@@ -125,6 +190,13 @@ var RootCmd = &cobra.Command{
 		}
 		log.SetLevel(logLevel(verbosity))
 
+		var sensitivePaths []utils.SensitivePath
+		if err := viper.UnmarshalKey(projectKeySensitivePaths, &sensitivePaths); err != nil {
+			return fmt.Errorf("invalid %q in %s: %w", projectKeySensitivePaths, viper.ConfigFileUsed(), err)
+		}
+		redactor = utils.NewRedactor(sensitivePaths)
+		cmdLockfile = nil
+
 		// Ask me how much I love glog/klog's interface.
 		logflags := goflag.NewFlagSet(os.Args[0], goflag.ExitOnError)
 		klog.InitFlags(logflags)
@@ -136,6 +208,9 @@ var RootCmd = &cobra.Command{
 
 		return nil
 	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		return saveLockfile()
+	},
 }
 
 // clientConfig.Namespace() is broken in client-go 3.0:
@@ -199,6 +274,26 @@ func (f *logFormatter) Format(e *log.Entry) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// redactionHook scrubs the sensitive values seen by redactor from log messages and their fields.
+type redactionHook struct{}
+
+func (redactionHook) Levels() []log.Level { return log.AllLevels }
+
+func (redactionHook) Fire(e *log.Entry) error {
+	e.Message = redactor.RedactString(e.Message)
+	for k, v := range e.Data {
+		switch v := v.(type) {
+		case string:
+			e.Data[k] = redactor.RedactString(v)
+		case error:
+			e.Data[k] = redactor.RedactString(v.Error())
+		case fmt.Stringer:
+			e.Data[k] = redactor.RedactString(v.String())
+		}
+	}
+	return nil
+}
+
 // NB: `path` is assumed to be in native-OS path separator form
 func dirURL(path string) *url.URL {
 	path = filepath.ToSlash(path)
@@ -209,9 +304,31 @@ func dirURL(path string) *url.URL {
 	return &url.URL{Scheme: "file", Path: path}
 }
 
+// importerOptions returns the options for fetching remote content outside of a jsonnet VM
+// (e.g. OCI bundles or jsonnet-bundler dependencies), according to command line flags.
+func importerOptions(cmd *cobra.Command) ([]utils.ImporterOption, error) {
+	opts := []utils.ImporterOption{utils.WithOffline(viper.GetBool(flagOffline))}
+	if dir := viper.GetString(flagCacheDir); dir != "" {
+		opts = append(opts, utils.WithDiskCache(utils.NewDiskCache(dir)))
+	}
+	policy, err := importPolicy(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		opts = append(opts, utils.WithImportPolicy(policy))
+	}
+	keys, err := trustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, utils.WithTrustedKeys(keys))
+	return opts, nil
+}
+
 // JsonnetVM constructs a new jsonnet.VM, according to command line
-// flags
-func JsonnetVM(cmd *cobra.Command) (*jsonnet.VM, error) {
+// flags. The extra options are applied last and can thus override flags.
+func JsonnetVM(cmd *cobra.Command, extra ...kubecfg.JsonnetVMOpt) (*jsonnet.VM, error) {
 	var opts []kubecfg.JsonnetVMOpt
 
 	flags := cmd.Flags()
@@ -232,6 +349,44 @@ func JsonnetVM(cmd *cobra.Command) (*jsonnet.VM, error) {
 	opts = append(opts, kubecfg.WithImportURLs(sURLs...))
 
 	opts = append(opts, kubecfg.WithAlpha(viper.GetBool(flagAlpha)))
+	opts = append(opts, kubecfg.WithCacheDir(viper.GetString(flagCacheDir)))
+	opts = append(opts, kubecfg.WithOffline(viper.GetBool(flagOffline)))
+
+	policy, err := importPolicy(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kubecfg.WithImportPolicy(policy))
+
+	var credentials []utils.HTTPCredential
+	if err := viper.UnmarshalKey(projectKeyHTTPAuth, &credentials); err != nil {
+		return nil, fmt.Errorf("invalid %q in %s: %w", projectKeyHTTPAuth, viper.ConfigFileUsed(), err)
+	}
+	auth, err := utils.NewHTTPAuth(credentials, utils.DefaultNetrcPath())
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kubecfg.WithHTTPAuth(auth))
+
+	keys, err := trustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kubecfg.WithTrustedKeys(keys))
+
+	opts = append(opts, kubecfg.WithSopsKeys(utils.SopsKeys{
+		AgeKeyFiles: projectRelativePaths(flagSopsAgeKeyFile),
+		PGPKeyFiles: projectRelativePaths(flagSopsPGPKeyFile),
+	}))
+
+	resolverType := kubecfg.ParseResolverType(viper.GetString(flagResolver))
+
+	// The semver resolver pins the tags it picks in the lockfile.
+	lockfile, err := loadLockfile(resolverType == kubecfg.SemverResolver)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kubecfg.WithLockfile(lockfile))
 
 	withVar := func(typ vars.Type, expr vars.ExpressionType, source vars.Source) func(string, string) {
 		return func(name, value string) {
@@ -239,10 +394,21 @@ func JsonnetVM(cmd *cobra.Command) (*jsonnet.VM, error) {
 		}
 	}
 
-	resolverType := kubecfg.ParseResolverType(viper.GetString(flagResolver))
 	resolverFailureAction := kubecfg.ParseResolverFailureAction(viper.GetString(flagResolvFail))
 	opts = append(opts, kubecfg.WithResolver(resolverType, resolverFailureAction))
 
+	platform, err := resolverPlatform()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kubecfg.WithResolverPlatform(platform))
+
+	rewriteRules, err := imageRewriteRules()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kubecfg.WithImageRewriteRules(rewriteRules))
+
 	for _, spec := range []struct {
 		flagName string
 		fromFile bool
@@ -283,7 +449,7 @@ func JsonnetVM(cmd *cobra.Command) (*jsonnet.VM, error) {
 		}
 	}
 
-	return kubecfg.JsonnetVM(opts...)
+	return kubecfg.JsonnetVM(append(opts, extra...)...)
 }
 
 func readObjs(cmd *cobra.Command, paths []string, opts ...utils.ReadOption) ([]*unstructured.Unstructured, error) {
@@ -293,6 +459,17 @@ func readObjs(cmd *cobra.Command, paths []string, opts ...utils.ReadOption) ([]*
 	if err != nil {
 		return nil, err
 	}
+
+	ociEntrypoint, err := flags.GetString(flagOCIEntrypoint)
+	if err != nil {
+		return nil, err
+	}
+	if ociEntrypoint != "" {
+		if paths, err = selectOCIEntrypoint(paths, ociEntrypoint); err != nil {
+			return nil, err
+		}
+	}
+
 	if exec != "" {
 		paths = append(paths, utils.ToDataURL(exec))
 	}
@@ -331,15 +508,174 @@ func readObjs(cmd *cobra.Command, paths []string, opts ...utils.ReadOption) ([]*
 		opts = append(opts, utils.WithOverlayCode(overlayCode))
 	}
 
-	return readObjsInternal(cmd, paths, opts...)
+	var hasher *utils.ConfigHasher
+	if mode := viper.GetString(flagConfigHash); mode != "" {
+		namespace, err := defaultNamespace(clientConfig)
+		if err != nil {
+			log.Debugf("Cannot read the default namespace, assuming %q: %v", metav1.NamespaceDefault, err)
+			namespace = metav1.NamespaceDefault
+		}
+		if hasher, err = utils.NewConfigHasher(clusterSchema(cmd), mode, namespace); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagConfigHash, err)
+		}
+	}
+
+	objs, err := readObjsInternal(cmd, paths, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if hasher != nil {
+		if err := hasher.HashObjects(objs); err != nil {
+			return nil, fmt.Errorf("hashing ConfigMaps and Secrets: %w", err)
+		}
+	}
+	redactor.Observe(objs...)
+	return objs, nil
+}
+
+// selectOCIEntrypoint selects the named entrypoint of the OCI bundles in paths
+// that don't already select one with a URL fragment.
+func selectOCIEntrypoint(paths []string, name string) ([]string, error) {
+	res := make([]string, len(paths))
+	found := false
+	for i, p := range paths {
+		res[i] = p
+		if strings.HasPrefix(p, "oci://") || strings.HasPrefix(p, utils.OCILayoutScheme+"://") {
+			found = true
+			if !strings.Contains(p, "#") {
+				res[i] = p + "#" + name
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("--%s requires an OCI bundle as input", flagOCIEntrypoint)
+	}
+	return res, nil
+}
+
+// imageRewriteRules returns the rules of the mirror image resolver in the project file.
+func imageRewriteRules() ([]utils.ImageRewriteRule, error) {
+	var rules []utils.ImageRewriteRule
+	if err := viper.UnmarshalKey(projectKeyImageRewrite, &rules); err != nil {
+		return nil, fmt.Errorf("invalid %q in %s: %w", projectKeyImageRewrite, viper.ConfigFileUsed(), err)
+	}
+	return rules, nil
+}
+
+// resolverPlatform returns the platform set with --platform, or nil.
+func resolverPlatform() (*v1.Platform, error) {
+	s := viper.GetString(flagPlatform)
+	if s == "" {
+		return nil, nil
+	}
+	platform, err := v1.ParsePlatform(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flagPlatform, err)
+	}
+	return platform, nil
+}
+
+// cmdLockfile is the lockfile of the running command, shared by the importer, the image resolver
+// and --pin-images. Its new pins are written once the command succeeds.
+var cmdLockfile *utils.Lockfile
+
+// lockfilePath returns the path set by --lock-file. Relative paths are relative to the
+// project directory, unless given on the command line.
+func lockfilePath() string {
+	path := viper.GetString(flagLockFile)
+	if path != "" && !filepath.IsAbs(path) && !RootCmd.PersistentFlags().Changed(flagLockFile) {
+		path = filepath.Join(projectDir(), path)
+	}
+	return path
+}
+
+// loadLockfile loads the lockfile set by --lock-file, if any. A missing lockfile disables
+// the checks, unless create is true.
+func loadLockfile(create bool) (*utils.Lockfile, error) {
+	if cmdLockfile != nil {
+		return cmdLockfile, nil
+	}
+	path := lockfilePath()
+	if path == "" {
+		return nil, nil
+	}
+	mode := utils.LockEnforce
+	if viper.GetBool(flagUpdateLock) {
+		mode = utils.LockUpdate
+	}
+	lockfile, err := utils.LoadLockfile(path, mode)
+	if err == nil && lockfile == nil && create {
+		lockfile, err = utils.LoadLockfile(path, utils.LockUpdate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cmdLockfile = lockfile
+	return lockfile, nil
+}
+
+// saveLockfile writes the new pins of the lockfile, if any.
+func saveLockfile() error {
+	if !cmdLockfile.Changed() {
+		return nil
+	}
+	log.Infof("Updating %s", cmdLockfile.Path())
+	return cmdLockfile.Save()
+}
+
+// clusterSchema returns the schema of the cluster, if it can be reached. It is used
+// to find the pod specs of the kinds that are not built in, e.g. custom resources.
+func clusterSchema(cmd *cobra.Command) discovery.OpenAPISchemaInterface {
+	if viper.GetBool(flagOffline) {
+		return nil
+	}
+	_, _, disco, err := getDynamicClients(cmd)
+	if err != nil {
+		log.Debugf("not using the cluster schema to find pod specs: %v", err)
+		return nil
+	}
+	return disco
 }
 
 func readObjsInternal(cmd *cobra.Command, paths []string, opts ...utils.ReadOption) ([]*unstructured.Unstructured, error) {
+	if !viper.GetBool(flagPinImages) {
+		vm, err := JsonnetVM(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return kubecfg.ReadObjects(vm, paths, opts...)
+	}
+
+	// Imports and images share the lockfile, which is created if needed.
+	lockfile, err := loadLockfile(true)
+	if err != nil {
+		return nil, err
+	}
 	vm, err := JsonnetVM(cmd)
 	if err != nil {
 		return nil, err
 	}
-	return kubecfg.ReadObjects(vm, paths, opts...)
+	objs, err := kubecfg.ReadObjects(vm, paths, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	platform, err := resolverPlatform()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := imageRewriteRules()
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := kubecfg.NewPinningResolver(kubecfg.ParseResolverType(viper.GetString(flagResolver)), rules, platform)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.NewImagePinner(resolver, lockfile, clusterSchema(cmd), viper.GetBool(flagOffline), platform).PinObjects(objs); err != nil {
+		return nil, fmt.Errorf("pinning images: %w", err)
+	}
+	return objs, nil
 
 }
 
@@ -382,7 +718,101 @@ func getDynamicClients(cmd *cobra.Command) (dynamic.Interface, meta.RESTMapper,
 	return cl, mapper, discoCache, nil
 }
 
+// projectFile is the project file found by initConfig, if any. Viper can't forget a config
+// file once set, so it's tracked here for each command.
+var projectFile string
+
 func initConfig() {
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("KUBECFG")
+
+	projectFile = ""
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Debugf("cannot determine current working directory: %v", err)
+		return
+	}
+	path, found, err := utils.SearchUp(projectFileName, filepath.Join(cwd, projectFileName))
+	if err != nil || !found {
+		return
+	}
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		log.Warnf("Ignoring %s: %v", path, err)
+		return
+	}
+	projectFile = path
+}
+
+// projectDir returns the directory containing the project file, or the current directory if there is none.
+func projectDir() string {
+	if projectFile != "" {
+		return filepath.Dir(projectFile)
+	}
+	return "."
+}
+
+// trustedKeys returns the public keys OCI bundles must be signed with, or nil if
+// signatures are not verified. Relative paths in the project file are relative to it.
+func trustedKeys() ([]crypto.PublicKey, error) {
+	if !viper.GetBool(flagVerifyBundles) {
+		return nil, nil
+	}
+	paths := projectRelativePaths(flagTrustedKey)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("--%s requires at least one --%s", flagVerifyBundles, flagTrustedKey)
+	}
+	return utils.LoadPublicKeys(paths)
+}
+
+// projectRelativePaths returns the paths set with the flag flagName, which are relative
+// to the project file when set in it.
+func projectRelativePaths(flagName string) []string {
+	var paths []string
+	for _, p := range viper.GetStringSlice(flagName) {
+		if !filepath.IsAbs(p) && !RootCmd.PersistentFlags().Changed(flagName) {
+			p = filepath.Join(projectDir(), p)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// importPolicy returns the import policy configured by flags or in the project file,
+// or nil if no restriction is configured.
+// Relative roots are relative to the current directory when given as flags, and to
+// the project file otherwise.
+func importPolicy(cmd *cobra.Command) (*utils.ImportPolicy, error) {
+	p := &utils.ImportPolicy{
+		AllowedHosts:   viper.GetStringSlice(flagImportAllowHost),
+		AllowedSchemes: viper.GetStringSlice(flagImportAllowScheme),
+	}
+
+	base := projectDir()
+	if cmd.Flags().Changed(flagImportAllowRoot) {
+		base = "."
+	}
+	for _, root := range viper.GetStringSlice(flagImportAllowRoot) {
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(base, root)
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		p.AllowedRoots = append(p.AllowedRoots, abs)
+	}
+
+	if s := viper.GetString(flagImportMaxSize); s != "" {
+		q, err := resource.ParseQuantity(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagImportMaxSize, err)
+		}
+		p.MaxDownloadSize = q.Value()
+	}
+
+	if len(p.AllowedRoots) == 0 && len(p.AllowedHosts) == 0 && len(p.AllowedSchemes) == 0 && p.MaxDownloadSize == 0 {
+		return nil, nil
+	}
+	return p, nil
 }
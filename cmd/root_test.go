@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/kubecfg/kubecfg/utils"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestReadObjsDuplicates(t *testing.T) {
@@ -56,3 +64,76 @@ func TestReadObjsDuplicatesLiteralShowProvenance(t *testing.T) {
 		t.Fatalf("got: %s, want: nil", got)
 	}
 }
+
+func TestLockfileInProjectDir(t *testing.T) {
+	body := "{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'a' } }"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	project := t.TempDir()
+	sub := filepath.Join(project, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(project, projectFileName): "{}\n",
+		filepath.Join(sub, "main.jsonnet"):      "import '" + srv.URL + "/lib.libsonnet'",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	cmdOutput(t, []string{"show", "--update-lock", "main.jsonnet"})
+	b, err := os.ReadFile(filepath.Join(project, utils.DefaultLockfileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), srv.URL+"/lib.libsonnet") {
+		t.Errorf("import not pinned:\n%s", b)
+	}
+	if _, err := os.Stat(filepath.Join(sub, utils.DefaultLockfileName)); err == nil {
+		t.Errorf("lockfile written in the current directory")
+	}
+
+	body = "{ apiVersion: 'v1', kind: 'ConfigMap', metadata: { name: 'b' } }"
+	if err := cmdError(t, []string{"show", "main.jsonnet"}); err == nil || !strings.Contains(err.Error(), "integrity check failed") {
+		t.Errorf("expected integrity error, got: %v", err)
+	}
+}
+
+func TestRedactionHook(t *testing.T) {
+	saved := redactor
+	t.Cleanup(func() { redactor = saved })
+	redactor = utils.NewRedactor(nil)
+	redactor.Observe(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "s"},
+		"stringData": map[string]interface{}{"password": "hunter2!"},
+	}})
+
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+	logger.AddHook(redactionHook{})
+	logger.WithFields(log.Fields{
+		"value": "hunter2!",
+		"count": 1,
+	}).WithError(errors.New("bad password hunter2!")).Warn("password is hunter2!")
+
+	if got := buf.String(); strings.Contains(got, "hunter2!") || !strings.Contains(got, "count=1") {
+		t.Errorf("log entry not redacted: %s", got)
+	}
+}
@@ -31,6 +31,7 @@ const (
 	flagExportFileNameExt    = "export-filename-extension"
 	flagShowProvenance       = "show-provenance"
 	flagReorder              = "reorder"
+	flagRedactSecrets        = "redact-secrets"
 )
 
 func init() {
@@ -42,6 +43,7 @@ func init() {
 	cmd.PersistentFlags().String(flagExportFileNameExt, "", fmt.Sprintf("Override the file extension used when creating filenames when using %s", flagExportFileNameFormat))
 	cmd.PersistentFlags().Bool(flagShowProvenance, false, "Add provenance annotations showing the file and the field path to each rendered k8s object")
 	cmd.PersistentFlags().String(flagReorder, "", "--reorder=server: Reorder resources like the 'update' command does. --reorder=client: TODO")
+	cmd.PersistentFlags().Bool(flagRedactSecrets, false, "Hide the values of Secrets and of the sensitive paths of the project file")
 
 	addCommonEvalFlags(cmd)
 }
@@ -79,6 +81,14 @@ var showCmd = &cobra.Command{
 			return err
 		}
 
+		redactSecrets, err := flags.GetBool(flagRedactSecrets)
+		if err != nil {
+			return err
+		}
+		if redactSecrets {
+			c.Redactor = redactor
+		}
+
 		showProvenance, err := flags.GetBool(flagShowProvenance)
 		if err != nil {
 			return err
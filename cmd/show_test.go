@@ -348,3 +348,34 @@ metadata:
 		t.Fatalf("got: %q, want: %q", got, want)
 	}
 }
+
+func TestShowRedactSecrets(t *testing.T) {
+	const (
+		input = `[
+	{ apiVersion: "v1", kind: "Secret", metadata: { name: "foo" }, stringData: { password: "hunter2" } },
+	{ apiVersion: "v1", kind: "ConfigMap", metadata: { name: "foo" }, data: { password: "hunter2" } },
+]`
+
+		want = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: foo
+stringData:
+  password: (redacted)
+---
+apiVersion: v1
+data:
+  password: hunter2
+kind: ConfigMap
+metadata:
+  name: foo
+`
+	)
+
+	got := cmdOutput(t, []string{"show", "-e", input, "--redact-secrets"})
+
+	if got != want {
+		t.Fatalf("got: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func writeKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(name, typ string, marshal func() ([]byte, error)) string {
+		der, err := marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	priv := write("cosign.key", "PRIVATE KEY", func() ([]byte, error) { return x509.MarshalPKCS8PrivateKey(key) })
+	pub := write("cosign.pub", "PUBLIC KEY", func() ([]byte, error) { return x509.MarshalPKIXPublicKey(key.Public().(crypto.PublicKey)) })
+	return priv, pub
+}
+
+func cmdError(t *testing.T, args []string) error {
+	defer resetFlags()
+	RootCmd.SetOutput(io.Discard)
+	defer RootCmd.SetOutput(nil)
+
+	t.Log("Running args", args)
+	RootCmd.SetArgs(args)
+	return RootCmd.Execute()
+}
+
+func TestSignAndVerify(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	priv, pub := writeKeyPair(t)
+	_, untrusted := writeKeyPair(t)
+
+	signed := host + "/demo:signed"
+	unsigned := host + "/demo:unsigned"
+	cmdOutput(t, []string{"--alpha", "pack", signed, prepareTestData(t), "--insecure-registry", "--sign-key", priv})
+	// the annotation makes the manifest digest differ from the signed one
+	cmdOutput(t, []string{"--alpha", "pack", unsigned, prepareTestData(t), "--insecure-registry", "--annotation", "tampered=true"})
+
+	cmdOutput(t, []string{"inspect", "--cache-dir=", "--verify-bundles", "--trusted-key", untrusted, "--trusted-key", pub, signed})
+
+	testCases := []struct {
+		ref  string
+		key  string
+		want string
+	}{
+		{signed, untrusted, "no valid signature"},
+		{unsigned, pub, "cannot find signature"},
+	}
+	for _, tc := range testCases {
+		err := cmdError(t, []string{"inspect", "--cache-dir=", "--verify-bundles", "--trusted-key", tc.key, tc.ref})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got: %v", tc.ref, tc.want, err)
+		}
+	}
+
+	// A bundle and its signature copied to another repository are rejected.
+	copied := host + "/other:signed"
+	dgst, err := crane.Digest(signed, crane.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigTag := strings.Replace(dgst, ":", "-", 1) + ".sig"
+	for src, dst := range map[string]string{signed: copied, host + "/demo:" + sigTag: host + "/other:" + sigTag} {
+		if err := crane.Copy(src, dst, crane.Insecure); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmdError(t, []string{"inspect", "--cache-dir=", "--verify-bundles", "--trusted-key", pub, copied}); err == nil || !strings.Contains(err.Error(), "no valid signature") {
+		t.Errorf("%s: expected error containing %q, got: %v", copied, "no valid signature", err)
+	}
+
+	if err := cmdError(t, []string{"inspect", "--cache-dir=", "--verify-bundles", signed}); err == nil {
+		t.Error("expected error without trusted keys")
+	}
+
+	// Signatures are also verified for imports.
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.jsonnet")
+	if err := os.WriteFile(main, []byte("import 'oci://"+unsigned+"'"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdError(t, []string{"show", "--alpha", "--cache-dir=", "--verify-bundles", "--trusted-key", pub, main}); err == nil {
+		t.Error("expected unsigned bundle import to fail")
+	}
+}
@@ -54,7 +54,7 @@ var updateCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		flags := cmd.Flags()
 		var err error
-		c := kubecfg.UpdateCmd{}
+		c := kubecfg.UpdateCmd{Redactor: redactor}
 
 		validate, err := flags.GetBool(flagValidate)
 		if err != nil {
@@ -0,0 +1,65 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/kubecfg/kubecfg/pkg/kubecfg"
+)
+
+const (
+	flagVendorDir    = "dir"
+	flagVendorUpdate = "update"
+)
+
+func init() {
+	RootCmd.AddCommand(vendorCmd)
+	vendorCmd.PersistentFlags().String(flagVendorDir, ".", "Directory containing jsonnetfile.json")
+	vendorCmd.PersistentFlags().Bool(flagVendorUpdate, false, "Ignore the versions pinned in jsonnetfile.lock.json and fetch the latest ones")
+}
+
+var vendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Short: "Install the jsonnet libraries listed in jsonnetfile.json into vendor/",
+	Long: `Install the jsonnet libraries listed in jsonnetfile.json (the jsonnet-bundler format) into vendor/
+and pin their versions in jsonnetfile.lock.json.
+
+Libraries in vendor/ are importable without -J when kubecfg runs in a directory containing
+jsonnetfile.json or in one of its subdirectories.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		flags := cmd.Flags()
+		c := kubecfg.VendorCmd{}
+
+		c.Dir, err = flags.GetString(flagVendorDir)
+		if err != nil {
+			return err
+		}
+		c.Update, err = flags.GetBool(flagVendorUpdate)
+		if err != nil {
+			return err
+		}
+
+		c.ImporterOpts, err = importerOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		return c.Run(cmd.OutOrStdout())
+	},
+}
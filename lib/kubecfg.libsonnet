@@ -36,11 +36,126 @@
   // string encoded as a single YAML document.
   manifestYaml:: std.native('manifestYaml'),
 
+  // parseToml(data): parse the `data` string as a TOML document, and
+  // return the resulting jsonnet object. Dates and times are returned
+  // as RFC 3339 strings.
+  parseToml:: std.native('parseToml'),
+
+  // manifestToml(value): convert the jsonnet object `value` to a
+  // string encoded as a TOML document, with sorted keys. Integral
+  // numbers are encoded as integers.
+  manifestToml:: std.native('manifestToml'),
+
+  // parseIni(data): parse the `data` string as an INI document. The
+  // keys before the first section are fields of the result, and each
+  // section is an object field. All the values are strings.
+  parseIni:: std.native('parseIni'),
+
+  // manifestIni(value): convert the jsonnet object `value` to a string
+  // encoded as an INI document, with sorted keys. The object fields of
+  // `value` are sections, the other fields come before the first one.
+  manifestIni:: std.native('manifestIni'),
+
+  // parseHcl(data): parse the `data` string as an HCL (version 1)
+  // document, like Vault and Consul configurations. Blocks are
+  // returned as arrays of objects.
+  parseHcl:: std.native('parseHcl'),
+
+  // parseCsv(data, header=true): parse the `data` string as CSV. If
+  // `header` is true, the first record holds the field names, and an
+  // array of objects is returned; otherwise an array of arrays of
+  // strings is returned.
+  parseCsv(data, header=true):: std.native('parseCsv')(data, header),
+
+  // renderGoTemplate(template, data, options={}): render the Go
+  // text/template `template` with `data`, with the sprig functions that
+  // always return the same result: the ones reading environment variables,
+  // the current time or the network, and the ones returning random values,
+  // keys or certificates, are not available. E.g.
+  // renderGoTemplate(importstr 'nginx.conf.tmpl', {port: 8080}).
+  // `options` may set:
+  //   strict: if true, a reference to a missing key is an error,
+  //     instead of rendering "<no value>".
+  //   delims: the left and right delimiters, e.g. ['[[', ']]'],
+  //     instead of '{{' and '}}'.
+  renderGoTemplate(template, data, options={}):: (
+    std.native('renderGoTemplate')(template, data, options)
+  ),
+
+  // query(obj, expr, language='jsonpath'): return the array of the
+  // values of `obj` matching `expr`. `language` is 'jsonpath', for
+  // kubectl JSONPath expressions, whose braces and leading dot may be
+  // omitted, e.g. query(chart, "[?(@.kind=='Service')].metadata.name"),
+  // or 'jq', for jq expressions, e.g. query(chart, '.[] | .kind').
+  query(obj, expr, language='jsonpath'):: (
+    std.native('query')(obj, expr, language)
+  ),
+
+  // jsonPatch(obj, ops): apply the array of RFC 6902 JSON Patch
+  // operations `ops` to `obj`, e.g.
+  // jsonPatch(deploy, [{op: 'remove', path: '/spec/template/spec/containers/0/args/1'}]).
+  jsonPatch:: std.native('jsonPatch'),
+
+  // mergePatch(obj, patch): apply the RFC 7386 JSON merge patch
+  // `patch` to `obj`. Null fields of `patch` remove fields of `obj`.
+  mergePatch:: std.native('mergePatch'),
+
+  // strategicMergePatch(obj, patch): apply the strategic merge patch
+  // `patch` to the Kubernetes object `obj`, like kubectl patch and
+  // kustomize do, e.g. merging containers by name. The patch
+  // strategies come from the built-in Kubernetes types; other kinds,
+  // like custom resources, are patched with mergePatch.
+  strategicMergePatch:: std.native('strategicMergePatch'),
+
   // escapeStringRegex(s): Quote the regex metacharacters found in s.
   // The result is a regex that will match the original literal
   // characters.
   escapeStringRegex:: std.native('escapeStringRegex'),
 
+  // decryptSops(data): decrypt the content of a YAML or JSON file
+  // encrypted by sops with age or PGP keys, e.g.
+  // decryptSops(importstr 'secret.enc.yaml'). The keys are read from
+  // the --sops-age-key-file and --sops-pgp-key-file flags, or from
+  // the SOPS_AGE_KEY_FILE and SOPS_AGE_KEY environment variables.
+  decryptSops:: std.native('decryptSops'),
+
+  // sealSecret(secret, certPem, scope='strict'): encrypt the Secret
+  // object `secret` into a bitnami.com/v1alpha1 SealedSecret, with the
+  // certificate of the sealed-secrets controller, e.g.
+  // sealSecret(secret, importstr 'sealed-secrets.pem'). `scope` is
+  // 'strict', 'namespace-wide' or 'cluster-wide'. The result differs
+  // at every evaluation.
+  sealSecret(secret, certPem, scope='strict'):: (
+    std.native('sealSecret')(secret, certPem, scope)
+  ),
+
+  // sha256(data), sha512(data): return the hex encoded digest of
+  // `data`, a string or an array of bytes (e.g. from importbin).
+  sha256:: std.native('sha256'),
+  sha512:: std.native('sha512'),
+
+  // hmacSha256(key, data), hmacSha512(key, data): return the hex
+  // encoded HMAC of `data` with `key`. Both are strings or arrays of
+  // bytes.
+  hmacSha256:: std.native('hmacSha256'),
+  hmacSha512:: std.native('hmacSha512'),
+
+  // hexEncode(data): encode `data`, a string or an array of bytes, as
+  // a hex string.
+  hexEncode:: std.native('hexEncode'),
+
+  // hexDecode(str): decode the hex string `str` into an array of
+  // bytes.
+  hexDecode:: std.native('hexDecode'),
+
+  // bcrypt(password, cost=10): return the bcrypt hash of `password`.
+  // The salt is random, so the result differs at every evaluation.
+  bcrypt(password, cost=10):: std.native('bcrypt')(password, cost),
+
+  // htpasswd(user, password): return a line of an htpasswd file, with
+  // the bcrypt hash of `password`.
+  htpasswd(user, password):: user + ':' + self.bcrypt(password),
+
   // resolveImage(image): convert the docker image string from
   // image:tag into a more specific image@digest, depending on kubecfg
   // command line flags.
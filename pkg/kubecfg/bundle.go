@@ -0,0 +1,274 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package kubecfg
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+)
+
+// bundleVendorDir is the directory of the bundle where remote imports are stored.
+const bundleVendorDir = "_vendor"
+
+const binaryImportPrefix = "binary://"
+
+// bundleFile is a file to be stored in a bundle.
+type bundleFile struct {
+	url *url.URL
+	// code is set for files imported with `import`, whose own imports are followed and rewritten.
+	// Files only imported with importstr or importbin are stored as they are.
+	code    bool
+	imports []bundleImport
+	name    string // path in the bundle
+}
+
+// bundleImport is an import statement of a bundled file.
+type bundleImport struct {
+	loc    ast.LocationRange
+	path   string // the imported path, as written
+	code   bool   // import rather than importstr, importbin or import "binary://..."
+	target string // URL of the imported file
+}
+
+// collectBundleFiles returns the roots and all the files they transitively import with import, importstr and importbin,
+// except the ones provided by kubecfg itself.
+func collectBundleFiles(vm *jsonnet.VM, roots ...*url.URL) ([]*bundleFile, error) {
+	files := map[string]*bundleFile{}
+
+	var visit func(foundAt string, code bool) error
+	visit = func(foundAt string, code bool) error {
+		f, found := files[foundAt]
+		if found && (f.code || !code) {
+			return nil
+		}
+		if !found {
+			u, err := url.Parse(foundAt)
+			if err != nil {
+				return err
+			}
+			f = &bundleFile{url: u}
+			files[foundAt] = f
+		}
+		f.code = code
+		if !code {
+			return nil
+		}
+
+		content, _, err := vm.ImportData(".", foundAt)
+		if err != nil {
+			return err
+		}
+		node, err := jsonnet.SnippetToAST(foundAt, content)
+		if err != nil {
+			return err
+		}
+
+		var imports []bundleImport
+		walkImports(node, func(lit *ast.LiteralString, code bool) {
+			code = code && !strings.HasPrefix(lit.Value, binaryImportPrefix)
+			imports = append(imports, bundleImport{loc: lit.LocRange, path: lit.Value, code: code})
+		})
+		for i := range imports {
+			imp := &imports[i]
+			var target string
+			if imp.code {
+				_, target, err = vm.ImportAST(foundAt, imp.path)
+			} else {
+				target, err = vm.ResolveImport(foundAt, imp.path)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", foundAt, err)
+			}
+			u, err := url.Parse(strings.TrimSuffix(target, "##binaryImport"))
+			if err != nil {
+				return err
+			}
+			if u.Scheme == "internal" {
+				continue
+			}
+			imp.target = u.String()
+			f.imports = append(f.imports, *imp)
+			if err := visit(imp.target, imp.code); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range roots {
+		if err := visit(root.String(), true); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]*bundleFile, 0, len(files))
+	for _, f := range files {
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// walkImports calls fn with the path literal of every import, importstr and importbin in node.
+// code is true for import.
+func walkImports(node ast.Node, fn func(lit *ast.LiteralString, code bool)) {
+	switch i := node.(type) {
+	case *ast.Import:
+		fn(i.File, true)
+	case *ast.ImportStr:
+		fn(i.File, false)
+	case *ast.ImportBin:
+		fn(i.File, false)
+	}
+	for _, c := range toolutils.Children(node) {
+		walkImports(c, fn)
+	}
+}
+
+// nameBundleFiles sets the paths of the files in the bundle and returns the path of rootURL.
+// Local files are stored relative to their common parent directory, remote files under _vendor/
+// in a path derived from their URL.
+func nameBundleFiles(files []*bundleFile, rootURL *url.URL) (string, error) {
+	var (
+		local      []*url.URL
+		localFiles []*bundleFile
+	)
+	for _, f := range files {
+		if f.url.Scheme == "file" {
+			local = append(local, f.url)
+			localFiles = append(localFiles, f)
+		} else {
+			f.name = vendoredName(f.url)
+		}
+	}
+	sort.Slice(localFiles, func(i, j int) bool { return localFiles[i].url.String() < localFiles[j].url.String() })
+	sort.Slice(local, func(i, j int) bool { return local[i].String() < local[j].String() })
+	short, shortEntrypoint := shortNames(local, rootURL)
+	for i, f := range localFiles {
+		f.name = short[i]
+	}
+
+	seen := map[string]*bundleFile{}
+	for _, f := range files {
+		if other, found := seen[f.name]; found {
+			return "", fmt.Errorf("%s and %s would both be bundled as %q", other.url.Redacted(), f.url.Redacted(), f.name)
+		}
+		seen[f.name] = f
+	}
+	return shortEntrypoint, nil
+}
+
+// vendoredName returns the path of a remote file in the bundle, e.g.
+// _vendor/https/example.com/lib/foo.libsonnet for https://example.com/lib/foo.libsonnet, or
+// _vendor/oci/ghcr.io/org/bundle_v1/main.jsonnet for oci://ghcr.io/org/bundle:v1/main.jsonnet.
+func vendoredName(u *url.URL) string {
+	segments := []string{bundleVendorDir, u.Scheme, u.Host}
+	if u.RawQuery != "" {
+		segments = append(segments, u.RawQuery)
+	}
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	if strings.HasSuffix(u.Path, "/") || u.Path == "" {
+		// the root of an OCI bundle, which imports its entrypoint
+		segments = append(segments, "_entrypoint.jsonnet")
+	}
+	for i := range segments {
+		segments[i] = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._+-", r) {
+				return r
+			}
+			return '_'
+		}, segments[i])
+	}
+	return path.Join(segments...)
+}
+
+// rewriteImports rewrites the imports of the file f whose content is src, so that they point to the bundled files.
+// Imports that already resolve to the right file relatively to f are left untouched.
+func rewriteImports(src string, f *bundleFile, names map[string]string) (string, error) {
+	lineStarts := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(l ast.Location) int {
+		if l.Line < 1 || l.Line > len(lineStarts) {
+			return -1
+		}
+		return lineStarts[l.Line-1] + l.Column - 1
+	}
+
+	imports := append([]bundleImport(nil), f.imports...)
+	sort.Slice(imports, func(i, j int) bool { return offset(imports[i].loc.Begin) > offset(imports[j].loc.Begin) })
+
+	dir := path.Dir(f.name)
+	last := -1
+	for _, imp := range imports {
+		// desugaring can duplicate the nodes of an import
+		if offset(imp.loc.Begin) == last {
+			continue
+		}
+		last = offset(imp.loc.Begin)
+
+		name, found := names[imp.target]
+		if !found {
+			return "", fmt.Errorf("%s: import %q resolved to %s, which is not bundled", f.url.Redacted(), imp.path, imp.target)
+		}
+		prefix := ""
+		p := imp.path
+		if strings.HasPrefix(p, binaryImportPrefix) {
+			prefix, p = binaryImportPrefix, strings.TrimPrefix(p, binaryImportPrefix)
+		}
+		if !strings.Contains(p, "://") && path.Join(dir, p) == name {
+			continue
+		}
+
+		begin, end := offset(imp.loc.Begin), offset(imp.loc.End)
+		if begin < 0 || end > len(src) || begin >= end || !strings.ContainsRune(`'"@`, rune(src[begin])) {
+			return "", fmt.Errorf("%s: cannot locate import %q", f.url.Redacted(), imp.path)
+		}
+		src = src[:begin] + strconv.Quote(prefix+relativePath(dir, name)) + src[end:]
+	}
+	return src, nil
+}
+
+// relativePath returns the slash separated path of target relative to the directory dir.
+func relativePath(dir, target string) string {
+	from := strings.Split(path.Clean(dir), "/")
+	to := strings.Split(path.Clean(target), "/")
+	if from[0] == "." {
+		from = nil
+	}
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+	var res []string
+	for range from[i:] {
+		res = append(res, "..")
+	}
+	return path.Join(append(res, to[i:]...)...)
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package kubecfg
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+// CacheListCmd represents the cache list subcommand
+type CacheListCmd struct {
+	Dir string
+}
+
+func (c CacheListCmd) Run(out io.Writer) error {
+	if c.Dir == "" {
+		return fmt.Errorf("the cache is disabled")
+	}
+	entries, err := utils.NewDiskCache(c.Dir).Entries()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tLOCATION\tDIGEST\tFETCHED")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Kind, e.Key, e.Digest, e.Fetched.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// CachePruneCmd represents the cache prune subcommand
+type CachePruneCmd struct {
+	Dir string
+	// OlderThan selects the entries fetched longer than OlderThan ago. Zero means all entries.
+	OlderThan time.Duration
+}
+
+func (c CachePruneCmd) Run(out io.Writer) error {
+	if c.Dir == "" {
+		return fmt.Errorf("the cache is disabled")
+	}
+	entries, blobs, err := utils.NewDiskCache(c.Dir).Prune(c.OlderThan)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Removed %d entries and %d blobs from %s\n", entries, blobs, c.Dir)
+	return nil
+}
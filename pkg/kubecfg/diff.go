@@ -45,14 +45,15 @@ var ErrDiffFound = fmt.Errorf("Differences found.")
 // Matches all the line starts on a diff text, which is where we put diff markers and indent
 var DiffLineStart = regexp.MustCompile("(^|\n)(.)")
 
-var DiffKeyValue = regexp.MustCompile(`"([-._a-zA-Z0-9]+)":\s"([[:alnum:]=+]+)",?`)
-
 // DiffCmd represents the diff subcommand
 type DiffCmd struct {
 	Client           dynamic.Interface
 	Mapper           meta.RESTMapper
 	DefaultNamespace string
 	OmitSecrets      bool
+	// Redactor hides the sensitive values when OmitSecrets is set.
+	// If nil, the default sensitive paths are redacted.
+	Redactor *utils.Redactor
 
 	DiffStrategy string
 }
@@ -109,8 +110,17 @@ func (c DiffCmd) diff(out io.Writer, desc string, color bool, liveObj, obj *unst
 	if err != nil {
 		return false, err
 	}
+	objMap := obj.Object
+	if c.OmitSecrets {
+		redactor := c.Redactor
+		if redactor == nil {
+			redactor = utils.NewRedactor(nil)
+		}
+		live, config := redactor.RedactPair(&unstructured.Unstructured{Object: liveObjMap}, obj)
+		liveObjMap, objMap = live.Object, config.Object
+	}
 	liveObjText, _ := json.MarshalIndent(liveObjMap, "", "  ")
-	objText, _ := json.MarshalIndent(obj.Object, "", "  ")
+	objText, _ := json.MarshalIndent(objMap, "", "  ")
 	diff := dmp.DiffMain(string(liveObjText), string(objText), false)
 
 	if (len(diff) == 1) && (diff[0].Type == diffmatchpatch.DiffEqual) {
@@ -118,7 +128,7 @@ func (c DiffCmd) diff(out io.Writer, desc string, color bool, liveObj, obj *unst
 	} else {
 		edits := myers.ComputeEdits(span.URIFromPath(""), string(liveObjText), string(objText))
 		diff := gotextdiff.ToUnified("", "", string(liveObjText), edits)
-		formatDiff(out, diff, color, c.OmitSecrets && obj.GetKind() == "Secret")
+		formatDiff(out, diff, color)
 
 		fmt.Fprintf(out, "\n")
 		return true, nil
@@ -128,8 +138,7 @@ func (c DiffCmd) diff(out io.Writer, desc string, color bool, liveObj, obj *unst
 }
 
 // Formats the supplied Diff as a unified-diff-like text with infinite context and optionally colorizes it.
-// If omitvalues is true, the value part of the `"key": "value"` lines is omitted (useful to not render secrets) in CI output.
-func formatDiff(f io.Writer, u gotextdiff.Unified, color bool, omitvalues bool) {
+func formatDiff(f io.Writer, u gotextdiff.Unified, color bool) {
 	if len(u.Hunks) == 0 {
 		return
 	}
@@ -160,9 +169,6 @@ func formatDiff(f io.Writer, u gotextdiff.Unified, color bool, omitvalues bool)
 		fmt.Fprint(f, " @@\n")
 		for _, l := range hunk.Lines {
 			text := l.Content
-			if omitvalues {
-				text = DiffKeyValue.ReplaceAllString(text, "$1: <omitted>")
-			}
 			switch l.Kind {
 			case gotextdiff.Delete:
 				if color {
@@ -175,9 +181,7 @@ func formatDiff(f io.Writer, u gotextdiff.Unified, color bool, omitvalues bool)
 				}
 				fmt.Fprintf(f, "+%s", text)
 			default:
-				if !omitvalues {
-					fmt.Fprintf(f, " %s", text)
-				}
+				fmt.Fprintf(f, " %s", text)
 			}
 			if color {
 				_, _ = io.WriteString(f, "\x1b[0m")
@@ -195,7 +199,7 @@ func (c DiffCmd) getLiveObjObject(obj *unstructured.Unstructured, liveObj *unstr
 		liveObjObject = removeMapFields(obj.Object, liveObj.Object)
 	} else if c.DiffStrategy == "last-applied" {
 		var err error
-		liveObjObject, err = origObject(liveObj)
+		liveObjObject, err = origObject(liveObj, c.Redactor)
 		if err != nil {
 			return nil, err
 		}
@@ -84,8 +84,12 @@ func TestDiffSecret(t *testing.T) {
 			"metadata": map[string]interface{}{
 				"name": "foo",
 			},
-			"data": map[string]string{
-				"foo": "YmFy",
+			"data": map[string]interface{}{
+				"foo":  "YmFy",
+				"same": "c2FtZQ==",
+			},
+			"stringData": map[string]interface{}{
+				"cert": "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
 			},
 		},
 	}
@@ -96,8 +100,12 @@ func TestDiffSecret(t *testing.T) {
 			"metadata": map[string]interface{}{
 				"name": "foo",
 			},
-			"data": map[string]string{
-				"foo": "Zm9v",
+			"data": map[string]interface{}{
+				"foo":  "Zm9v",
+				"same": "c2FtZQ==",
+			},
+			"stringData": map[string]interface{}{
+				"cert": "-----BEGIN CERTIFICATE-----\nMIIC\n-----END CERTIFICATE-----\n",
 			},
 		},
 	}
@@ -114,11 +122,27 @@ func TestDiffSecret(t *testing.T) {
 	want := `--- live resource
 +++ config resource
 @@ -1,7 +1,7 @@
--    foo: <omitted>
-+    foo: <omitted>
+ {
+   "apiVersion": "v1",
+   "data": {
+-    "foo": "(redacted)",
++    "foo": "(redacted, changed)",
+     "same": "(redacted)"
+   },
+   "kind": "Secret",
+@@ -9,6 +9,6 @@
+     "name": "foo"
+   },
+   "stringData": {
+-    "cert": "(redacted)"
++    "cert": "(redacted, changed)"
+   }
+ }
+\ No newline at end of file
 
 `
-	require.Equal(t, buf.String(), want)
+	require.Equal(t, want, buf.String())
+	require.NotContains(t, buf.String(), "MII")
 }
 
 func TestLastAppliedStrategy(t *testing.T) {
@@ -0,0 +1,208 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package kubecfg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+// InspectCmd represents the inspect subcommand
+type InspectCmd struct {
+	// Format is either "text" or "json".
+	Format string
+	// ImporterOpts configure how the bundle is fetched (cache, offline mode, policy).
+	ImporterOpts []utils.ImporterOption
+}
+
+type bundleInfo struct {
+	Reference   string           `json:"reference"`
+	Digest      string           `json:"digest"`
+	Manifest    ocispec.Manifest `json:"manifest"`
+	Entrypoint  string           `json:"entrypoint"`
+	Entrypoints []entrypointInfo `json:"entrypoints"`
+	Metadata    json.RawMessage  `json:"metadata,omitempty"`
+	Files       []string         `json:"files"`
+	Docs        []string         `json:"docs,omitempty"`
+}
+
+type entrypointInfo struct {
+	// Name is empty for the default entrypoint.
+	Name string    `json:"name,omitempty"`
+	Path string    `json:"path"`
+	TLAs []tlaInfo `json:"tlas,omitempty"`
+}
+
+// tlaInfo describes a top level argument of an entrypoint.
+type tlaInfo struct {
+	Name     string `json:"name"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+func (e entrypointInfo) String() string {
+	if len(e.TLAs) == 0 {
+		return e.Path
+	}
+	var tlas []string
+	for _, a := range e.TLAs {
+		if a.Optional {
+			tlas = append(tlas, a.Name+" (optional)")
+		} else {
+			tlas = append(tlas, a.Name)
+		}
+	}
+	return fmt.Sprintf("%s (TLAs: %s)", e.Path, strings.Join(tlas, ", "))
+}
+
+func (c InspectCmd) Run(ctx context.Context, ref string, out io.Writer) error {
+	bundle, err := utils.FetchOCIBundle(ctx, ref, c.ImporterOpts...)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %w", ref, err)
+	}
+	info := bundleInfo{
+		Reference:  strings.TrimPrefix(ref, "oci://"),
+		Digest:     bundle.ManifestDescriptor().Digest.String(),
+		Manifest:   bundle.Manifest(),
+		Entrypoint: bundle.Config().Entrypoint,
+		Metadata:   bundle.Config().Metadata,
+		Files:      bundle.Files(),
+		Docs:       bundle.DocFiles(),
+	}
+	names := append([]string{""}, sortedKeys(bundle.Config().Entrypoints)...)
+	for _, name := range names {
+		path, err := bundle.Config().EntrypointPath(name)
+		if err != nil {
+			continue // a bundle without default entrypoint
+		}
+		e := entrypointInfo{Name: name, Path: path}
+		if e.TLAs, err = bundleTLAs(bundle, path); err != nil {
+			return err
+		}
+		info.Entrypoints = append(info.Entrypoints, e)
+	}
+
+	switch c.Format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	case "text", "":
+		return printBundleInfo(out, info)
+	default:
+		return fmt.Errorf("unsupported output format %q; supported values are: text, json", c.Format)
+	}
+}
+
+// bundleTLAs returns the top level arguments of the bundle file path, if it evaluates to a function.
+func bundleTLAs(bundle *utils.OCIBundle, path string) ([]tlaInfo, error) {
+	r, err := bundle.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening entrypoint %q: %w", path, err)
+	}
+	defer r.Close()
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	node, err := jsonnet.SnippetToAST(path, string(src))
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch n := node.(type) {
+		case *ast.Local:
+			node = n.Body
+			continue
+		case *ast.Parens:
+			node = n.Inner
+			continue
+		case *ast.Function:
+			var res []tlaInfo
+			for _, p := range n.Parameters {
+				res = append(res, tlaInfo{Name: string(p.Name), Optional: p.DefaultArg != nil})
+			}
+			return res, nil
+		}
+		return nil, nil
+	}
+}
+
+func printBundleInfo(out io.Writer, info bundleInfo) error {
+	fmt.Fprintf(out, "Reference:  %s\n", info.Reference)
+	fmt.Fprintf(out, "Digest:     %s\n", info.Digest)
+	for _, e := range info.Entrypoints {
+		if e.Name == "" {
+			fmt.Fprintf(out, "Entrypoint: %s\n", e)
+		}
+	}
+	if len(info.Entrypoints) > 0 && info.Entrypoints[len(info.Entrypoints)-1].Name != "" {
+		fmt.Fprintln(out, "Entrypoints:")
+		for _, e := range info.Entrypoints {
+			if e.Name != "" {
+				fmt.Fprintf(out, "  %s: %s\n", e.Name, e)
+			}
+		}
+	}
+
+	if len(info.Manifest.Annotations) > 0 {
+		fmt.Fprintln(out, "Annotations:")
+		var keys []string
+		for k := range info.Manifest.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(out, "  %s: %s\n", k, info.Manifest.Annotations[k])
+		}
+	}
+
+	if len(info.Metadata) > 0 {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, info.Metadata, "  ", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Metadata:\n  %s\n", buf.String())
+	}
+
+	fmt.Fprintln(out, "Layers:")
+	fmt.Fprintf(out, "  %s %s (%d bytes, config)\n", info.Manifest.Config.MediaType, info.Manifest.Config.Digest, info.Manifest.Config.Size)
+	for _, l := range info.Manifest.Layers {
+		fmt.Fprintf(out, "  %s %s (%d bytes)\n", l.MediaType, l.Digest, l.Size)
+	}
+
+	fmt.Fprintln(out, "Files:")
+	for _, f := range info.Files {
+		fmt.Fprintf(out, "  %s\n", f)
+	}
+	if len(info.Docs) > 0 {
+		fmt.Fprintln(out, "Docs:")
+		for _, f := range info.Docs {
+			fmt.Fprintf(out, "  %s\n", f)
+		}
+	}
+	return nil
+}
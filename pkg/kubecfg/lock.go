@@ -0,0 +1,53 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package kubecfg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/go-jsonnet"
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+// LockCmd represents the lock subcommand
+type LockCmd struct {
+	// Lockfile must be the same lockfile the jsonnet VM has been configured with.
+	Lockfile *utils.Lockfile
+}
+
+// Run imports all the files transitively imported by paths, letting the importer pin
+// all the remote imports, and then writes the lockfile.
+func (c LockCmd) Run(vm *jsonnet.VM, paths []string, out io.Writer) error {
+	var urls []string
+	for _, p := range paths {
+		u, err := utils.PathToURL(p)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, u)
+	}
+
+	if _, err := vm.FindDependencies(".", urls); err != nil {
+		return err
+	}
+
+	if err := c.Lockfile.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s\n", c.Lockfile.Path())
+	return nil
+}
@@ -20,14 +20,19 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -37,9 +42,14 @@ import (
 	"github.com/kubecfg/kubecfg/pkg/oci"
 	"github.com/kubecfg/kubecfg/pkg/version"
 	"github.com/kubecfg/kubecfg/utils"
+	"github.com/opencontainers/go-digest"
 	"github.com/opencontainers/image-spec/specs-go"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
+	ocistore "oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
 )
 
 const (
@@ -53,9 +63,14 @@ type PackCmd struct {
 	InsecureRegistry bool // use HTTP if true
 	DocsTarFile      string
 	Annotations      []string
+	SignKey          string // sign the pushed bundle with this private key, if set
+	// Entrypoints are additional named entrypoints, given as name=file.
+	Entrypoints []string
 }
 
-func (c PackCmd) Run(ctx context.Context, vm *jsonnet.VM, ociPackage string, rootFile string) (err error) {
+// Run packs rootFile and pushes it as ociPackage, then prints the digest of the
+// bundle manifest (or of the bundle body when writing it to OutputFile) to out.
+func (c PackCmd) Run(ctx context.Context, vm *jsonnet.VM, ociPackage string, rootFile string, out io.Writer) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("packing %q: %w", rootFile, err)
@@ -71,14 +86,36 @@ func (c PackCmd) Run(ctx context.Context, vm *jsonnet.VM, ociPackage string, roo
 		return err
 	}
 
+	extraURLs := map[string]*url.URL{}
+	for _, e := range c.Entrypoints {
+		name, file, ok := strings.Cut(e, "=")
+		if !ok || name == "" || file == "" {
+			return fmt.Errorf("invalid entrypoint %q, must be name=file", e)
+		}
+		if _, dup := extraURLs[name]; dup {
+			return fmt.Errorf("duplicate entrypoint %q", name)
+		}
+		s, err := utils.PathToURL(file)
+		if err != nil {
+			return err
+		}
+		if extraURLs[name], err = url.Parse(s); err != nil {
+			return err
+		}
+	}
+
 	var bodyBlob bytes.Buffer
-	shortEntrypoint, err := bundleAllDependencies(&bodyBlob, vm, rootURL)
+	shortEntrypoint, entrypoints, err := bundleAllDependencies(&bodyBlob, vm, rootURL, extraURLs)
 	if err != nil {
 		return err
 	}
 
 	if c.OutputFile != "" {
-		return os.WriteFile(c.OutputFile, bodyBlob.Bytes(), 0666)
+		if err := os.WriteFile(c.OutputFile, bodyBlob.Bytes(), 0666); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out, digest.FromBytes(bodyBlob.Bytes()))
+		return err
 	}
 
 	metadata, err := bundleConfigMetadata(vm, rootURL)
@@ -86,7 +123,124 @@ func (c PackCmd) Run(ctx context.Context, vm *jsonnet.VM, ociPackage string, roo
 		return err
 	}
 
-	return c.pushOCIBundle(ctx, ociPackage, rootFile, bodyBlob.Bytes(), shortEntrypoint, metadata)
+	var signer crypto.Signer
+	if c.SignKey != "" {
+		if signer, err = utils.LoadSigningKey(c.SignKey); err != nil {
+			return err
+		}
+	}
+	bundle := packedBundle{
+		rootFile:    rootFile,
+		body:        bodyBlob.Bytes(),
+		entrypoint:  shortEntrypoint,
+		entrypoints: entrypoints,
+		metadata:    metadata,
+		signer:      signer,
+	}
+
+	var manifestDesc ocispec.Descriptor
+	if layout, found := strings.CutPrefix(ociPackage, utils.OCILayoutScheme+"://"); found {
+		manifestDesc, err = c.writeOCILayout(ctx, layout, bundle)
+	} else {
+		var repo *remote.Repository
+		if repo, err = oci.NewAuthenticatedRepository(ociPackage); err != nil {
+			return err
+		}
+		repo.PlainHTTP = c.InsecureRegistry
+		manifestDesc, err = c.pushOCIBundle(ctx, repo, ociPackage, bundle)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s@%s\n", ociPackage, manifestDesc.Digest)
+	return err
+}
+
+// packedBundle holds the content of a bundle to be pushed.
+type packedBundle struct {
+	rootFile    string
+	body        []byte
+	entrypoint  string
+	entrypoints map[string]string
+	metadata    json.RawMessage
+	signer      crypto.Signer // nil if the bundle is not signed
+}
+
+// writeOCILayout writes the bundle in the OCI image layout at path ("dir:tag" or "file.tar:tag").
+// A directory layout can hold several bundles, while a tarball is rewritten with the bundle only.
+func (c PackCmd) writeOCILayout(ctx context.Context, path string, bundle packedBundle) (ocispec.Descriptor, error) {
+	layoutPath, tag := utils.SplitOCILayoutRef(path)
+	if _, err := digest.Parse(tag); err == nil {
+		return ocispec.Descriptor{}, fmt.Errorf("%s: OCI image layouts must be written with a tag, not a digest", path)
+	}
+
+	dir := layoutPath
+	if utils.IsOCILayoutTarball(layoutPath) {
+		tmp, err := os.MkdirTemp("", "kubecfg-oci-layout-*")
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+	store, err := ocistore.NewWithContext(ctx, dir)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc, err := c.pushOCIBundle(ctx, store, tag, bundle)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if dir != layoutPath {
+		if err := writeTarball(layoutPath, dir); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return desc, nil
+}
+
+// writeTarball writes the content of dir in an uncompressed tarball at path.
+func writeTarball(path, dir string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	tw := tar.NewWriter(f)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr := reproducibleHeader(filepath.ToSlash(rel), 0)
+		if d.IsDir() {
+			hdr.Name += "/"
+			hdr.Mode = 0755
+			hdr.Typeflag = tar.TypeDir
+			return tw.WriteHeader(hdr)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		hdr.Size = int64(len(b))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
 }
 
 func bundleConfigMetadata(vm *jsonnet.VM, rootURL *url.URL) (json.RawMessage, error) {
@@ -110,81 +264,127 @@ func bundleConfigMetadata(vm *jsonnet.VM, rootURL *url.URL) (json.RawMessage, er
 	return json.RawMessage(metadata), nil
 }
 
-// Writes a targz to w, containing rootURL and all files transitively imported from rootURL.
-// The path names are trimmed to remove the common prefix. The so trimmed rootURL is returned.
-func bundleAllDependencies(w io.Writer, vm *jsonnet.VM, rootURL *url.URL) (string, error) {
-	urls, err := urlsToBePackaged(vm, rootURL)
+// Writes a targz to w, containing rootURL, the extra entrypoints and all files transitively imported from them.
+// Local path names are trimmed to remove the common prefix, remote imports are vendored under _vendor/
+// and the import statements are rewritten to point to the bundled files. The so trimmed rootURL is returned,
+// along with the trimmed paths of the extra entrypoints.
+func bundleAllDependencies(w io.Writer, vm *jsonnet.VM, rootURL *url.URL, extra map[string]*url.URL) (string, map[string]string, error) {
+	roots := []*url.URL{rootURL}
+	for _, name := range sortedKeys(extra) {
+		roots = append(roots, extra[name])
+	}
+	files, err := collectBundleFiles(vm, roots...)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
+	shortEntrypoint, err := nameBundleFiles(files, rootURL)
+	if err != nil {
+		return "", nil, err
+	}
+	names := make(map[string]string, len(files))
+	for _, f := range files {
+		names[f.url.String()] = f.name
+	}
+	var entrypoints map[string]string
+	for name, u := range extra {
+		if entrypoints == nil {
+			entrypoints = map[string]string{}
+		}
+		entrypoints[name] = names[u.String()]
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
 
-	short, shortEntrypoint := shortNames(urls, rootURL)
-
+	// The gzip header has no name and a zero modification time, so that packing
+	// the same files always produces the same digest.
 	fgz := gzip.NewWriter(w)
 	defer fgz.Close()
 	tw := tar.NewWriter(fgz)
 	defer tw.Close()
 
-	for i := range short {
-		content, _, err := vm.ImportData(".", urls[i].String())
+	for _, f := range files {
+		content, _, err := vm.ImportData(".", f.url.String())
 		if err != nil {
-			return "", err
+			return "", nil, err
+		}
+		if f.code {
+			if content, err = rewriteImports(content, f, names); err != nil {
+				return "", nil, err
+			}
 		}
 		b := []byte(content)
-		if err := tw.WriteHeader(&tar.Header{Name: short[i], Size: int64(len(b)), Mode: 0666}); err != nil {
-			return "", err
+		if err := tw.WriteHeader(reproducibleHeader(f.name, int64(len(b)))); err != nil {
+			return "", nil, err
 		}
 		if _, err := tw.Write(b); err != nil {
-			return "", err
+			return "", nil, err
 		}
 	}
-	return shortEntrypoint, nil
+	return shortEntrypoint, entrypoints, nil
 }
 
-func (c PackCmd) pushOCIBundle(ctx context.Context, ref string, rootFile string, bodyBlob []byte, entryPoint string, bundleConfigMetadata json.RawMessage) error {
-	repo, err := oci.NewAuthenticatedRepository(ref)
-	if err != nil {
-		return err
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	repo.PlainHTTP = c.InsecureRegistry
+	sort.Strings(keys)
+	return keys
+}
 
-	bodyDesc := content.NewDescriptorFromBytes(utils.OCIBundleBodyMediaType, bodyBlob)
-	if err := repo.Push(ctx, bodyDesc, bytes.NewReader(bodyBlob)); err != nil {
-		return err
+// reproducibleHeader returns the header of a regular file without any timestamp, ownership or
+// permission taken from the packing environment.
+func reproducibleHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatPAX,
+	}
+}
+
+// pushOCIBundle pushes the bundle to target (a registry repository or an OCI image layout) and tags it with ref.
+// It returns the descriptor of the bundle manifest.
+func (c PackCmd) pushOCIBundle(ctx context.Context, target oras.Target, ref string, bundle packedBundle) (ocispec.Descriptor, error) {
+	bodyDesc := content.NewDescriptorFromBytes(utils.OCIBundleBodyMediaType, bundle.body)
+	if err := pushBlob(ctx, target, bodyDesc, bundle.body); err != nil {
+		return ocispec.Descriptor{}, err
 	}
 
 	bundleConfig := utils.OCIBundleConfig{
-		Entrypoint: entryPoint,
-		Metadata:   bundleConfigMetadata,
+		Entrypoint:  bundle.entrypoint,
+		Entrypoints: bundle.entrypoints,
+		Metadata:    bundle.metadata,
 	}
 	configBlob, err := json.Marshal(bundleConfig)
 	if err != nil {
-		return err
+		return ocispec.Descriptor{}, err
 	}
 	configDesc := content.NewDescriptorFromBytes(utils.OCIBundleConfigMediaType, configBlob)
 	configDesc.ArtifactType = utils.OCIBundleConfigArtifactType
-	if err := repo.Push(ctx, configDesc, bytes.NewReader(configBlob)); err != nil {
-		return err
+	if err := pushBlob(ctx, target, configDesc, configBlob); err != nil {
+		return ocispec.Descriptor{}, err
 	}
 
 	layers := []ocispec.Descriptor{bodyDesc}
 
 	if c.DocsTarFile != "" {
 		if !strings.HasSuffix(c.DocsTarFile, ".tar.gz") && !strings.HasSuffix(c.DocsTarFile, ".tgz") {
-			return fmt.Errorf("--docs-tar-file currently supports only gzipped tar archives (required .tar.gz or .tgz file extension)")
+			return ocispec.Descriptor{}, fmt.Errorf("--docs-tar-file currently supports only gzipped tar archives (required .tar.gz or .tgz file extension)")
 		}
 		b, err := os.ReadFile(c.DocsTarFile)
 		if err != nil {
-			return err
+			return ocispec.Descriptor{}, err
 		}
 		docsDesc := content.NewDescriptorFromBytes(utils.OCIBundleDocsMediaType, b)
-		if err := repo.Push(ctx, docsDesc, bytes.NewReader(b)); err != nil {
-			return err
+		if err := pushBlob(ctx, target, docsDesc, b); err != nil {
+			return ocispec.Descriptor{}, err
 		}
 		layers = append(layers, docsDesc)
 	}
 
-	revision := getSourceRevision(rootFile)
+	revision := getSourceRevision(bundle.rootFile)
 
 	manifest := ocispec.Manifest{
 		Config:    configDesc,
@@ -201,20 +401,81 @@ func (c PackCmd) pushOCIBundle(ctx context.Context, ref string, rootFile string,
 		for _, a := range c.Annotations {
 			parts := strings.SplitN(a, "=", 2)
 			if len(parts) != 2 {
-				return fmt.Errorf("invalid annotation: %q", a)
+				return ocispec.Descriptor{}, fmt.Errorf("invalid annotation: %q", a)
 			}
 			manifest.Annotations[parts[0]] = parts[1]
 		}
 	}
 	manifestBlob, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	manifestDesc, err := oras.TagBytes(ctx, target, ocispec.MediaTypeImageManifest, manifestBlob, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if bundle.signer != nil {
+		if err := signOCIBundle(ctx, target, utils.OCIRepository(ref), bundle.signer, manifestDesc); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return manifestDesc, nil
+}
+
+// pushBlob pushes b unless target already has it.
+func pushBlob(ctx context.Context, target content.Pusher, desc ocispec.Descriptor, b []byte) error {
+	err := target.Push(ctx, desc, bytes.NewReader(b))
+	if errors.Is(err, errdef.ErrAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// signOCIBundle pushes a cosign compatible signature of the manifest desc, tagged sha256-<hex>.sig.
+// An existing signature of the same manifest is replaced.
+func signOCIBundle(ctx context.Context, target oras.Target, repo string, signer crypto.Signer, desc ocispec.Descriptor) error {
+	payload, err := utils.NewSimpleSigningPayload(repo, desc.Digest)
 	if err != nil {
 		return err
 	}
-	manifestDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestBlob)
-	if err := repo.PushReference(ctx, manifestDesc, bytes.NewReader(manifestBlob), ref); err != nil {
+	signature, err := utils.SignPayload(signer, payload)
+	if err != nil {
+		return fmt.Errorf("signing %s: %w", desc.Digest, err)
+	}
+
+	payloadDesc := content.NewDescriptorFromBytes(utils.CosignSimpleSigningMediaType, payload)
+	payloadDesc.Annotations = map[string]string{utils.CosignSignatureAnnotation: signature}
+	if err := pushBlob(ctx, target, payloadDesc, payload); err != nil {
 		return err
 	}
 
+	// cosign uses an image config listing the payload as the only layer.
+	configBlob, err := json.Marshal(ocispec.Image{
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{payloadDesc.Digest}},
+	})
+	if err != nil {
+		return err
+	}
+	configDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageConfig, configBlob)
+	if err := pushBlob(ctx, target, configDesc, configBlob); err != nil {
+		return err
+	}
+
+	manifestBlob, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{payloadDesc},
+	})
+	if err != nil {
+		return err
+	}
+	tag := utils.SignatureTag(desc.Digest)
+	if _, err := oras.TagBytes(ctx, target, ocispec.MediaTypeImageManifest, manifestBlob, tag); err != nil {
+		return fmt.Errorf("pushing signature %s: %w", tag, err)
+	}
+	log.Infof("Pushed signature %s", tag)
 	return nil
 }
 
@@ -232,35 +493,6 @@ func getSourceRevision(rootFile string) string {
 	return hash.String()
 }
 
-// returns rootFile parsed as a file URL along with a sorted list of files imported by rootFile
-// The url slice includes the rootFile itself.
-func urlsToBePackaged(vm *jsonnet.VM, rootURL *url.URL) ([]*url.URL, error) {
-	deps, err := vm.FindDependencies(".", []string{rootURL.String()})
-	if err != nil {
-		return nil, err
-	}
-
-	var urls []*url.URL
-	for _, d := range deps {
-		u, err := url.Parse(d)
-		if err != nil {
-			return nil, err
-		}
-		if u.Scheme == "internal" {
-			continue
-		}
-		if u.Scheme != "file" {
-			return nil, fmt.Errorf("unsupported scheme: %s", d)
-		}
-		urls = append(urls, u)
-	}
-
-	urls = append(urls, rootURL)
-	sort.Slice(urls, func(i, j int) bool { return urls[i].String() < urls[j].String() })
-	return urls, nil
-
-}
-
 func shortNames(urls []*url.URL, rootURL *url.URL) ([]string, string) {
 	s := make([]string, len(urls))
 	for i := range urls {
@@ -5,6 +5,9 @@ import (
 	"net/url"
 	"reflect"
 	"testing"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
 )
 
 func TestFindCommonPathPrefix(t *testing.T) {
@@ -87,3 +90,70 @@ func TestShortNames(t *testing.T) {
 	}
 
 }
+
+func TestVendoredName(t *testing.T) {
+	testCases := map[string]string{
+		"https://example.com/lib/foo.libsonnet":                     "_vendor/https/example.com/lib/foo.libsonnet",
+		"http://127.0.0.1:8080/foo.libsonnet":                       "_vendor/http/127.0.0.1_8080/foo.libsonnet",
+		"oci://ghcr.io/org/bundle:v1/main.jsonnet":                  "_vendor/oci/ghcr.io/org/bundle_v1/main.jsonnet",
+		"oci://ghcr.io/org/bundle:v1/":                              "_vendor/oci/ghcr.io/org/bundle_v1/_entrypoint.jsonnet",
+		"git+https://github.com/org/repo//lib/x.libsonnet?ref=v1.0": "_vendor/git+https/github.com/ref_v1.0/org/repo/lib/x.libsonnet",
+	}
+	for in, want := range testCases {
+		u, err := url.Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := vendoredName(u); got != want {
+			t.Errorf("%s: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	testCases := []struct {
+		dir, target, want string
+	}{
+		{".", "a/b.jsonnet", "a/b.jsonnet"},
+		{"a", "a/b.jsonnet", "b.jsonnet"},
+		{"a/c", "a/b.jsonnet", "../b.jsonnet"},
+		{"x/y", "_vendor/https/example.com/b.jsonnet", "../../_vendor/https/example.com/b.jsonnet"},
+	}
+	for _, tc := range testCases {
+		if got := relativePath(tc.dir, tc.target); got != tc.want {
+			t.Errorf("%s -> %s: got %q, want %q", tc.dir, tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestRewriteImports(t *testing.T) {
+	src := "local a = import 'https://example.com/a.libsonnet';\n{ b: importstr @'b.txt', c: import \"binary://https://example.com/c.bin\" }"
+	targets := map[string]string{
+		"https://example.com/a.libsonnet":    "https://example.com/a.libsonnet",
+		"b.txt":                              "file:///src/dir/b.txt",
+		"binary://https://example.com/c.bin": "https://example.com/c.bin",
+	}
+	names := map[string]string{
+		"https://example.com/a.libsonnet": "_vendor/https/example.com/a.libsonnet",
+		"https://example.com/c.bin":       "_vendor/https/example.com/c.bin",
+		"file:///src/dir/b.txt":           "dir/b.txt",
+	}
+
+	node, err := jsonnet.SnippetToAST("main.jsonnet", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &bundleFile{name: "dir/main.jsonnet"}
+	walkImports(node, func(lit *ast.LiteralString, code bool) {
+		f.imports = append(f.imports, bundleImport{loc: lit.LocRange, path: lit.Value, target: targets[lit.Value]})
+	})
+
+	got, err := rewriteImports(src, f, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "local a = import \"../_vendor/https/example.com/a.libsonnet\";\n{ b: importstr @'b.txt', c: import \"binary://../_vendor/https/example.com/c.bin\" }"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package kubecfg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+// PullCmd represents the pull subcommand
+type PullCmd struct {
+	// Dir is where the bundle body is extracted.
+	Dir string
+	// DocsDir is where the documentation layer is extracted. Defaults to Dir/_docs.
+	DocsDir string
+	// ImporterOpts configure how the bundle is fetched (cache, offline mode, policy).
+	ImporterOpts []utils.ImporterOption
+}
+
+func (c PullCmd) Run(ctx context.Context, ref string, out io.Writer) error {
+	bundle, err := utils.FetchOCIBundle(ctx, ref, c.ImporterOpts...)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %w", ref, err)
+	}
+
+	docsDir := c.DocsDir
+	if docsDir == "" {
+		docsDir = filepath.Join(c.Dir, "_docs")
+	}
+
+	for _, f := range bundle.Files() {
+		if err := extractBundleFile(c.Dir, f, bundle.Open); err != nil {
+			return err
+		}
+	}
+	for _, f := range bundle.DocFiles() {
+		if err := extractBundleFile(docsDir, f, bundle.OpenDoc); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "Pulled %s@%s into %s (%d files", strings.TrimPrefix(ref, "oci://"), bundle.ManifestDescriptor().Digest, c.Dir, len(bundle.Files()))
+	if n := len(bundle.DocFiles()); n > 0 {
+		fmt.Fprintf(out, ", %d docs in %s", n, docsDir)
+	}
+	fmt.Fprintf(out, ")\nEntrypoint: %s\n", bundle.Config().Entrypoint)
+	return nil
+}
+
+// extractBundleFile writes the file name of a bundle under dir, refusing names that would escape dir.
+func extractBundleFile(dir, name string, open func(string) (io.ReadCloser, error)) error {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.IsAbs(filepath.FromSlash(clean)) {
+		return fmt.Errorf("refusing to extract %q: path escapes the destination directory", name)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	r, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,29 @@
+package kubecfg
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractBundleFile(t *testing.T) {
+	dir := t.TempDir()
+	open := func(string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("content")), nil
+	}
+
+	if err := extractBundleFile(dir, "lib/./a.libsonnet", open); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lib", "a.libsonnet")); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"../evil.libsonnet", "lib/../../evil.libsonnet", "/etc/evil", ".."} {
+		if err := extractBundleFile(dir, name, open); err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Errorf("%q: expected path traversal error, got: %v", name, err)
+		}
+	}
+}
@@ -34,6 +34,8 @@ import (
 	// and also in other circumstances. We thus forked the go-yaml repo in:
 	"github.com/kubecfg/yaml/v2"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubecfg/kubecfg/utils"
 )
 
 const (
@@ -70,6 +72,8 @@ type ShowCmd struct {
 	// create one file per resource, using the fileNameTemplate Go template to derive
 	// the filename from the resource.
 	ExportDir string
+	// Redactor, if set, hides the sensitive values of the objects.
+	Redactor *utils.Redactor
 
 	fileNameTemplate *template.Template
 	fileNameExt      string
@@ -125,6 +129,9 @@ func (c ShowCmd) Run(apiObjects []*unstructured.Unstructured, out io.Writer) err
 	}
 
 	for i, obj := range apiObjects {
+		if c.Redactor != nil {
+			obj = c.Redactor.Redact(obj)
+		}
 		if err := c.renderObject(i, obj, out); err != nil {
 			return err
 		}
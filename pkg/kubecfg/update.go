@@ -85,6 +85,10 @@ type UpdateCmd struct {
 	GcNamespace     string
 	SkipGc          bool
 	DryRun          bool
+
+	// Redactor hides the sensitive values of the objects in the logs.
+	// If nil, the default sensitive paths are redacted.
+	Redactor *utils.Redactor
 }
 
 func isValidKindSchema(schema proto.Schema) bool {
@@ -99,7 +103,9 @@ func isValidKindSchema(schema proto.Schema) bool {
 	return err == nil
 }
 
-func origObject(existing *unstructured.Unstructured) (map[string]interface{}, error) {
+// origObject returns the object last applied by kubecfg, from the annotations of existing.
+// Its sensitive values are redacted by redactor, if not nil, in the logs.
+func origObject(existing *unstructured.Unstructured, redactor *utils.Redactor) (map[string]interface{}, error) {
 	annos := existing.GetAnnotations()
 	var origData []byte
 	if data := annos[AnnotationOrigObject]; data != "" {
@@ -118,12 +124,18 @@ func origObject(existing *unstructured.Unstructured) (map[string]interface{}, er
 		return nil, fmt.Errorf("no original object annotation")
 	}
 
-	log.Debugf("origData: %s", origData)
-
 	var liveObjObject map[string]interface{}
 	if err := json.Unmarshal(origData, &liveObjObject); err != nil {
 		return nil, err
 	}
+
+	if redactor == nil {
+		redactor = utils.NewRedactor(nil)
+	}
+	if data, err := redactor.Redact(&unstructured.Unstructured{Object: liveObjObject}).MarshalJSON(); err == nil {
+		log.Debugf("origData: %s", data)
+	}
+
 	return liveObjObject, nil
 }
 
@@ -194,7 +206,7 @@ func patch(existing, new *unstructured.Unstructured, schema proto.Schema) (*unst
 	return result.(*unstructured.Unstructured), nil
 }
 
-func createOrUpdate(ctx context.Context, rc dynamic.ResourceInterface, obj *unstructured.Unstructured, create bool, dryRun bool, schema proto.Schema, desc, dryRunText string) (*unstructured.Unstructured, error) {
+func createOrUpdate(ctx context.Context, rc dynamic.ResourceInterface, obj *unstructured.Unstructured, create bool, dryRun bool, schema proto.Schema, desc, dryRunText string, redactor *utils.Redactor) (*unstructured.Unstructured, error) {
 	existing, err := rc.Get(ctx, obj.GetName(), metav1.GetOptions{})
 	if create && errors.IsNotFound(err) {
 		log.Info("Creating ", desc, dryRunText)
@@ -209,7 +221,7 @@ func createOrUpdate(ctx context.Context, rc dynamic.ResourceInterface, obj *unst
 			return obj, nil
 		}
 		newobj, err := rc.Create(ctx, obj, metav1.CreateOptions{})
-		log.Debugf("Create(%s) returned (%v, %v)", obj.GetName(), newobj, err)
+		log.Debugf("Create(%s) returned (%v, %v)", obj.GetName(), redactor.Redact(newobj), err)
 		return newobj, err
 	}
 	if err != nil {
@@ -234,15 +246,15 @@ func createOrUpdate(ctx context.Context, rc dynamic.ResourceInterface, obj *unst
 		return mergedObj, nil
 	}
 
-	log.Debug("About to make change: ", diff.ObjectDiff(existing, mergedObj))
+	log.Debug("About to make change: ", diff.ObjectDiff(redactor.RedactPair(existing, mergedObj)))
 	log.Info("Updating ", desc, dryRunText)
 	if dryRun {
 		return mergedObj, nil
 	}
 	newobj, err := rc.Update(ctx, mergedObj, metav1.UpdateOptions{})
-	log.Debugf("Update(%s) returned (%v, %v)", mergedObj.GetName(), newobj, err)
+	log.Debugf("Update(%s) returned (%v, %v)", mergedObj.GetName(), redactor.Redact(newobj), err)
 	if err != nil {
-		log.Debug("Updated object: ", diff.ObjectDiff(existing, newobj))
+		log.Debug("Updated object: ", diff.ObjectDiff(redactor.RedactPair(existing, newobj)))
 	}
 	return newobj, err
 }
@@ -310,6 +322,11 @@ func (c UpdateCmd) Run(ctx context.Context, apiObjects []*unstructured.Unstructu
 		dryRunText = " (dry-run)"
 	}
 
+	redactor := c.Redactor
+	if redactor == nil {
+		redactor = utils.NewRedactor(nil)
+	}
+
 	log.Infof("Fetching schemas for %d resources", len(apiObjects))
 	depOrder, err := utils.DependencyOrder(c.Discovery, c.Mapper, apiObjects)
 	if err != nil {
@@ -361,7 +378,7 @@ func (c UpdateCmd) Run(ctx context.Context, apiObjects []*unstructured.Unstructu
 
 		var newobj *unstructured.Unstructured
 		err = retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
-			newobj, err = createOrUpdate(ctx, rc, obj, c.Create, c.DryRun, schema, desc, dryRunText)
+			newobj, err = createOrUpdate(ctx, rc, obj, c.Create, c.DryRun, schema, desc, dryRunText, redactor)
 			return
 		})
 		if err != nil {
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	pb_proto "github.com/golang/protobuf/proto"
 	openapi_v2 "github.com/google/gnostic/openapiv2"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -286,3 +289,33 @@ func TestPatchConflict(t *testing.T) {
 		t.Errorf("annotation was %q", value)
 	}
 }
+
+func TestOrigObjectRedacted(t *testing.T) {
+	hook := logtest.NewGlobal()
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	t.Cleanup(func() { log.SetLevel(level) })
+
+	existing := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name": "creds",
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"creds"},"stringData":{"password":"old-password"}}`,
+			},
+		},
+	}}
+	orig, err := origObject(existing, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orig["stringData"].(map[string]interface{})["password"]; got != "old-password" {
+		t.Errorf("got password %v", got)
+	}
+	for _, e := range hook.AllEntries() {
+		if strings.Contains(e.Message, "old-password") {
+			t.Errorf("sensitive value logged: %s", e.Message)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package kubecfg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+// VendorCmd represents the vendor subcommand
+type VendorCmd struct {
+	// Dir contains jsonnetfile.json. Dependencies are installed in Dir/vendor.
+	Dir string
+	// Update ignores the versions pinned in jsonnetfile.lock.json and resolves them again.
+	Update bool
+	// ImporterOpts configure how git repositories are fetched (cache, offline mode).
+	ImporterOpts []utils.ImporterOption
+}
+
+// Run installs all the dependencies listed in jsonnetfile.json, and transitively
+// the ones listed in their own jsonnetfile.json, and writes jsonnetfile.lock.json.
+// The vendor directory is fully managed and is recreated from scratch. It is only
+// replaced once all the dependencies have been installed.
+func (c VendorCmd) Run(out io.Writer) error {
+	jf, err := utils.LoadJsonnetfile(filepath.Join(c.Dir, utils.JsonnetfileName))
+	if err != nil {
+		return err
+	}
+
+	pinned := map[string]utils.JsonnetfileDependency{}
+	if !c.Update {
+		lock, err := utils.LoadJsonnetfile(filepath.Join(c.Dir, utils.JsonnetfileLockName))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if lock != nil {
+			for _, d := range lock.Dependencies {
+				pinned[d.InstallPath()] = d
+			}
+		}
+	}
+
+	vendorDir := filepath.Join(c.Dir, utils.JsonnetVendorDir)
+	if _, err := os.Stat(filepath.Join(vendorDir, "modules.txt")); err == nil {
+		return fmt.Errorf("%s is a Go vendor directory, not replacing it", vendorDir)
+	}
+	// The temporary directory is next to the vendor directory, so that the relative
+	// symlinks to local dependencies stay valid once it is renamed.
+	tmpDir, err := os.MkdirTemp(c.Dir, "."+utils.JsonnetVendorDir+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	v := &vendorer{
+		VendorCmd: c,
+		vendorDir: tmpDir,
+		pinned:    pinned,
+		installed: map[string]bool{},
+		legacy:    jf.LegacyImports,
+		out:       out,
+	}
+	if err := v.install(c.Dir, jf.Dependencies); err != nil {
+		return err
+	}
+	if err := replaceDir(tmpDir, vendorDir); err != nil {
+		return err
+	}
+
+	lock := &utils.Jsonnetfile{Version: 1, Dependencies: v.locked, LegacyImports: jf.LegacyImports}
+	if lock.Dependencies == nil {
+		lock.Dependencies = []utils.JsonnetfileDependency{}
+	}
+	return lock.Save(filepath.Join(c.Dir, utils.JsonnetfileLockName))
+}
+
+type vendorer struct {
+	VendorCmd
+	vendorDir string
+	pinned    map[string]utils.JsonnetfileDependency
+	installed map[string]bool
+	legacy    bool
+	out       io.Writer
+
+	locked []utils.JsonnetfileDependency
+}
+
+// install installs deps, whose local sources are relative to baseDir.
+// When two dependencies end up in the same place, the first one wins.
+func (v *vendorer) install(baseDir string, deps []utils.JsonnetfileDependency) error {
+	for _, d := range deps {
+		name := d.InstallPath()
+		if v.installed[name] {
+			continue
+		}
+		v.installed[name] = true
+		dest := filepath.Join(v.vendorDir, filepath.FromSlash(name))
+
+		if d.Source.Local != nil {
+			dir := d.Source.Local.Directory
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(baseDir, dir)
+			}
+			if err := v.symlink(dir, dest); err != nil {
+				return err
+			}
+			v.locked = append(v.locked, utils.JsonnetfileDependency{Source: d.Source, Name: d.Name})
+			fmt.Fprintf(v.out, "Linked %s -> %s\n", name, d.Source.Local.Directory)
+			if err := v.installNested(dir); err != nil {
+				return err
+			}
+			continue
+		}
+
+		version := d.Version
+		p, isPinned := v.pinned[name]
+		if isPinned {
+			version = p.Version
+		}
+		if err := v.checkDest(dest); err != nil {
+			return err
+		}
+		commit, err := utils.GitCheckout(d.Source.Git.Remote, version, d.Source.Git.Subdir, dest, v.ImporterOpts...)
+		if err != nil {
+			return err
+		}
+		sum, err := utils.HashDir(dest)
+		if err != nil {
+			return err
+		}
+		if isPinned && p.Sum != "" && p.Sum != sum {
+			return fmt.Errorf("checksum mismatch for %s at %s: %s pins %s but got %s", name, commit, utils.JsonnetfileLockName, p.Sum, sum)
+		}
+		log.Debugf("Vendored %s@%s (%s)", name, commit, sum)
+		fmt.Fprintf(v.out, "Vendored %s@%s\n", name, commit)
+
+		v.locked = append(v.locked, utils.JsonnetfileDependency{Source: d.Source, Version: commit, Sum: sum, Name: d.Name})
+		if v.legacy {
+			if err := v.symlink(dest, filepath.Join(v.vendorDir, d.LegacyName())); err != nil {
+				return err
+			}
+		}
+		if err := v.installNested(dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installNested installs the dependencies of a dependency, if it has a jsonnetfile.json.
+func (v *vendorer) installNested(dir string) error {
+	jf, err := utils.LoadJsonnetfile(filepath.Join(dir, utils.JsonnetfileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return v.install(dir, jf.Dependencies)
+}
+
+// checkDest makes sure that dest doesn't resolve outside of the vendor directory through a
+// symlink, like the one of a local dependency whose install path is a parent of dest.
+func (v *vendorer) checkDest(dest string) error {
+	existing := dest
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(v.vendorDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("cannot install %s: %s is outside of the vendor directory", dest, resolved)
+	}
+	return nil
+}
+
+// replaceDir replaces the directory dst with src.
+func replaceDir(src, dst string) error {
+	old := src + ".old"
+	if err := os.Rename(dst, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(old, dst)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// symlink creates a relative symlink at link pointing to target, unless link already exists.
+func (v *vendorer) symlink(target, link string) error {
+	if err := v.checkDest(link); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(link); err == nil {
+		log.Debugf("Not linking %s: already exists", link)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	absLinkDir, err := filepath.Abs(filepath.Dir(link))
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absLinkDir, absTarget)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(rel, link)
+}
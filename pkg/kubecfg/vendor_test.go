@@ -0,0 +1,166 @@
+package kubecfg
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/kubecfg/kubecfg/utils"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVendor(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack not available")
+	}
+
+	repoDir := t.TempDir()
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, repoDir, map[string]string{"lib/greeter/greeter.libsonnet": "{ greet(n): 'hello ' + n }"})
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("lib"); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := wt.Commit("init", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Unix(0, 0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"local-lib/local.libsonnet": "{ answer: 42 }",
+		"jsonnetfile.json": `{
+  "version": 1,
+  "dependencies": [
+    {"source": {"git": {"remote": "file://` + repoDir + `", "subdir": "lib/greeter"}}, "version": "master"},
+    {"source": {"local": {"directory": "local-lib"}}, "version": ""}
+  ]
+}`,
+	})
+
+	c := VendorCmd{Dir: dir}
+	if err := c.Run(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := utils.LoadJsonnetfile(filepath.Join(dir, utils.JsonnetfileLockName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(lock.Dependencies), 2; got != want {
+		t.Fatalf("got %d locked dependencies, want %d", got, want)
+	}
+	if got, want := lock.Dependencies[0].Version, commit.String(); got != want {
+		t.Errorf("got version %q, want %q", got, want)
+	}
+	if lock.Dependencies[0].Sum == "" {
+		t.Errorf("missing checksum in lock file")
+	}
+
+	// The vendor directory is used without -J, both with the full and the legacy names.
+	sub := filepath.Join(dir, "environments", "prod")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	vm, err := JsonnetVM(WithWorkingDir(sub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitInstallPath := strings.TrimPrefix(repoDir, "/") + "/lib/greeter"
+	writeFiles(t, sub, map[string]string{"main.jsonnet": `
+		(import '` + gitInstallPath + `/greeter.libsonnet').greet('world') + ' ' +
+		(import 'greeter/greeter.libsonnet').greet('again') + ' ' +
+		(import 'local-lib/local.libsonnet').answer
+	`})
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", "import 'file://"+filepath.ToSlash(sub)+"/main.jsonnet'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(out), `"hello world hello again 42"`; got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+
+	lock.Dependencies[0].Sum = "tampered"
+	if err := lock.Save(filepath.Join(dir, utils.JsonnetfileLockName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(io.Discard); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum error, got: %v", err)
+	}
+	// Failures leave the vendor directory untouched.
+	greeter := filepath.Join(dir, utils.JsonnetVendorDir, "greeter", "greeter.libsonnet")
+	if _, err := os.Stat(greeter); err != nil {
+		t.Errorf("vendor directory changed by a failed run: %v", err)
+	}
+
+	// Symlinks escaping the dependency are rejected.
+	if err := os.Symlink("../../outside", filepath.Join(repoDir, "lib", "greeter", "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("lib"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Commit("escape", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Unix(0, 0)}}); err != nil {
+		t.Fatal(err)
+	}
+	c.Update = true
+	if err := c.Run(io.Discard); err == nil || !strings.Contains(err.Error(), "points outside of the dependency") {
+		t.Errorf("expected symlink error, got: %v", err)
+	}
+	if _, err := os.Stat(greeter); err != nil {
+		t.Errorf("vendor directory changed by a failed run: %v", err)
+	}
+
+	// Links are created only in the vendor directory.
+	for dep, want := range map[string]string{
+		`{"source": {"local": {"directory": "lib/.."}}, "version": ""}`:                       "outside of the vendor directory",
+		`{"source": {"local": {"directory": "lib"}}, "version": "", "name": "../../escaped"}`: "invalid dependency name",
+	} {
+		linkDir := t.TempDir()
+		writeFiles(t, linkDir, map[string]string{
+			"lib/lib.libsonnet": "{}",
+			"jsonnetfile.json":  `{"version": 1, "dependencies": [` + dep + `]}`,
+		})
+		if err := (VendorCmd{Dir: linkDir}).Run(io.Discard); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q error, got: %v", dep, want, err)
+		}
+		if _, err := os.Lstat(filepath.Join(linkDir, "..", "escaped")); err == nil {
+			t.Errorf("%s: link created outside of the vendor directory", dep)
+		}
+	}
+
+	// Go vendor directories are not replaced.
+	goDir := t.TempDir()
+	writeFiles(t, goDir, map[string]string{
+		"jsonnetfile.json":   `{"version": 1, "dependencies": []}`,
+		"vendor/modules.txt": "# example.com/mod v1.0.0\n",
+	})
+	if err := (VendorCmd{Dir: goDir}).Run(io.Discard); err == nil || !strings.Contains(err.Error(), "Go vendor directory") {
+		t.Errorf("expected Go vendor directory error, got: %v", err)
+	}
+}
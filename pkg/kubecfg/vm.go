@@ -16,6 +16,7 @@
 package kubecfg
 
 import (
+	"crypto"
 	"fmt"
 	"net/url"
 	"os"
@@ -24,6 +25,7 @@ import (
 	"regexp"
 	"strings"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-jsonnet"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -34,14 +36,23 @@ import (
 )
 
 type jsonnetVMOpts struct {
-	alpha      bool
-	workingDir string
-	importPath []string
-	importURLs []string
-	vars       []vars.Var
+	alpha       bool
+	workingDir  string
+	importPath  []string
+	importURLs  []string
+	vars        []vars.Var
+	cacheDir    string
+	offline     bool
+	lockfile    *utils.Lockfile
+	policy      *utils.ImportPolicy
+	httpAuth    *utils.HTTPAuth
+	trustedKeys []crypto.PublicKey
 
 	resolverType          ResolverType
 	resolverFailureAction ResolverFailureAction
+	imageRewriteRules     []utils.ImageRewriteRule
+	resolverPlatform      *v1.Platform
+	sopsKeys              utils.SopsKeys
 }
 
 type JsonnetVMOpt func(*jsonnetVMOpts)
@@ -76,11 +87,55 @@ func WithVar(v vars.Var) JsonnetVMOpt {
 	}
 }
 
+// WithCacheDir enables the persistent cache of remote imports, stored in dir.
+func WithCacheDir(dir string) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.cacheDir = dir
+	}
+}
+
+// WithOffline forbids network access during evaluation; remote imports must be in the cache.
+func WithOffline(offline bool) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.offline = offline
+	}
+}
+
+// WithLockfile checks remote imports against the pins in the given lockfile.
+func WithLockfile(lockfile *utils.Lockfile) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.lockfile = lockfile
+	}
+}
+
+// WithImportPolicy restricts what can be imported. A nil policy allows everything.
+func WithImportPolicy(policy *utils.ImportPolicy) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.policy = policy
+	}
+}
+
+// WithHTTPAuth authenticates HTTP(S) imports with the given credentials.
+func WithHTTPAuth(auth *utils.HTTPAuth) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.httpAuth = auth
+	}
+}
+
+// WithTrustedKeys requires imported OCI bundles to be signed by one of the given keys.
+func WithTrustedKeys(keys []crypto.PublicKey) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.trustedKeys = keys
+	}
+}
+
 type ResolverType int
 
 const (
 	NoopResolver ResolverType = iota
 	RegistryResolver
+	MirrorResolver
+	SemverResolver
 )
 
 var (
@@ -88,11 +143,15 @@ var (
 	resolverTypeValue = map[string]ResolverType{
 		"noop":     NoopResolver,
 		"registry": RegistryResolver,
+		"mirror":   MirrorResolver,
+		"semver":   SemverResolver,
 	}
 	// resolverTypeName returns the string value for a ResolverType
 	resolverTypeName = map[ResolverType]string{
 		NoopResolver:     "noop",
 		RegistryResolver: "registry",
+		MirrorResolver:   "mirror",
+		SemverResolver:   "semver",
 	}
 )
 
@@ -115,6 +174,10 @@ func ParseResolverType(rt string) ResolverType {
 		return NoopResolver
 	case "registry":
 		return RegistryResolver
+	case "mirror":
+		return MirrorResolver
+	case "semver":
+		return SemverResolver
 	default:
 		return NoopResolver
 	}
@@ -176,6 +239,28 @@ func WithResolver(typ ResolverType, failureMode ResolverFailureAction) JsonnetVM
 	}
 }
 
+// WithImageRewriteRules sets the rules of the mirror resolver.
+func WithImageRewriteRules(rules []utils.ImageRewriteRule) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.imageRewriteRules = rules
+	}
+}
+
+// WithResolverPlatform makes the registry and mirror resolvers resolve multi-platform images
+// to the manifest of platform, rather than to their index.
+func WithResolverPlatform(platform *v1.Platform) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.resolverPlatform = platform
+	}
+}
+
+// WithSopsKeys sets the private keys used by the decryptSops native function.
+func WithSopsKeys(keys utils.SopsKeys) JsonnetVMOpt {
+	return func(opts *jsonnetVMOpts) {
+		opts.sopsKeys = keys
+	}
+}
+
 // JsonnetVM constructs a new jsonnet.VM, according to command line
 // flags
 func JsonnetVM(opt ...JsonnetVMOpt) (*jsonnet.VM, error) {
@@ -186,6 +271,14 @@ func JsonnetVM(opt ...JsonnetVMOpt) (*jsonnet.VM, error) {
 		o(&opts)
 	}
 
+	if opts.workingDir == "" {
+		var err error
+		opts.workingDir, err = os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine current working directory: %w", err)
+		}
+	}
+
 	var searchUrls []*url.URL
 	for _, p := range opts.importPath {
 		p, err := filepath.Abs(p)
@@ -195,6 +288,18 @@ func JsonnetVM(opt ...JsonnetVMOpt) (*jsonnet.VM, error) {
 		searchUrls = append(searchUrls, dirURL(p))
 	}
 
+	// Libraries installed by `kubecfg vendor` (or jsonnet-bundler) come after the explicit import paths.
+	vendorDir, found, err := utils.FindVendorDir(opts.workingDir)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if vendorDir, err = filepath.Abs(vendorDir); err != nil {
+			return nil, err
+		}
+		searchUrls = append(searchUrls, dirURL(vendorDir))
+	}
+
 	sURLs := opts.importURLs
 	// Special URL scheme used to find embedded content
 	sURLs = append(sURLs, "internal:///")
@@ -211,15 +316,7 @@ func JsonnetVM(opt ...JsonnetVMOpt) (*jsonnet.VM, error) {
 	}
 
 	for _, u := range searchUrls {
-		log.Debugln("Jsonnet search path:", u)
-	}
-
-	if opts.workingDir == "" {
-		var err error
-		opts.workingDir, err = os.Getwd()
-		if err != nil {
-			return nil, fmt.Errorf("unable to determine current working directory: %w", err)
-		}
+		log.Debugln("Jsonnet search path:", u.Redacted())
 	}
 
 	cwd := opts.workingDir
@@ -248,13 +345,29 @@ func JsonnetVM(opt ...JsonnetVMOpt) (*jsonnet.VM, error) {
 		v.Setter()(vm, name, value)
 	}
 
-	vm.Importer(utils.MakeUniversalImporter(searchUrls, opts.alpha))
+	importerOpts := []utils.ImporterOption{utils.WithOffline(opts.offline)}
+	if opts.cacheDir != "" {
+		importerOpts = append(importerOpts, utils.WithDiskCache(utils.NewDiskCache(opts.cacheDir)))
+	}
+	if opts.lockfile != nil {
+		importerOpts = append(importerOpts, utils.WithLockfile(opts.lockfile))
+	}
+	if opts.policy != nil {
+		importerOpts = append(importerOpts, utils.WithImportPolicy(opts.policy))
+	}
+	if opts.httpAuth != nil {
+		importerOpts = append(importerOpts, utils.WithHTTPAuth(opts.httpAuth))
+	}
+	if len(opts.trustedKeys) > 0 {
+		importerOpts = append(importerOpts, utils.WithTrustedKeys(opts.trustedKeys))
+	}
+	vm.Importer(utils.MakeUniversalImporter(searchUrls, opts.alpha, importerOpts...))
 
 	resolver, err := buildResolver(&opts)
 	if err != nil {
 		return nil, err
 	}
-	utils.RegisterNativeFuncs(vm, resolver)
+	utils.RegisterNativeFuncs(vm, resolver, utils.WithSopsKeys(opts.sopsKeys))
 
 	return vm, nil
 }
@@ -279,8 +392,14 @@ func buildResolver(opts *jsonnetVMOpts) (utils.Resolver, error) {
 	switch resolver := opts.resolverType; resolver {
 	case NoopResolver:
 		ret.Inner = utils.NewIdentityResolver()
-	case RegistryResolver:
-		ret.Inner = utils.NewRegistryResolver()
+	case RegistryResolver, MirrorResolver:
+		inner, err := NewPinningResolver(resolver, opts.imageRewriteRules, opts.resolverPlatform)
+		if err != nil {
+			return nil, err
+		}
+		ret.Inner = inner
+	case SemverResolver:
+		ret.Inner = utils.NewSemverResolver(opts.lockfile, utils.WithPlatform(opts.resolverPlatform))
 	default:
 		return nil, fmt.Errorf("bad value %d for resolver tyoe", resolver)
 	}
@@ -288,6 +407,17 @@ func buildResolver(opts *jsonnetVMOpts) (utils.Resolver, error) {
 	return &ret, nil
 }
 
+// NewPinningResolver returns the resolver of the images of rendered objects pinned by utils.ImagePinner.
+// Unlike the resolvers of JsonnetVM, it always looks up the registries, through the mirrors of rules
+// if resolverType is MirrorResolver.
+func NewPinningResolver(resolverType ResolverType, rules []utils.ImageRewriteRule, platform *v1.Platform) (utils.Resolver, error) {
+	registry := utils.NewRegistryResolver(utils.WithPlatform(platform))
+	if resolverType == MirrorResolver {
+		return utils.NewMirrorResolver(rules, registry)
+	}
+	return registry, nil
+}
+
 type resolverErrorWrapper struct {
 	Inner utils.Resolver
 	OnErr func(error) error
@@ -301,6 +431,13 @@ func (r *resolverErrorWrapper) Resolve(image *utils.ImageName) error {
 	return err
 }
 
+func (r *resolverErrorWrapper) ParseImage(image string) (utils.ImageName, error) {
+	if p, ok := r.Inner.(utils.ImageParser); ok {
+		return p.ParseImage(image)
+	}
+	return utils.ParseImageName(image)
+}
+
 // NB: `path` is assumed to be in native-OS path separator form
 func dirURL(path string) *url.URL {
 	path = filepath.ToSlash(path)
@@ -281,7 +281,7 @@ func PathToURL(path string) (string, error) {
 
 func isURL(path string) bool {
 	// TODO: figure a better way to tell filepaths and URLs apart (it also must work on windows...)
-	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "oci://") || strings.HasPrefix(path, "file://") || strings.HasPrefix(path, "data:,")
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "oci://") || strings.HasPrefix(path, OCILayoutScheme+"://") || strings.HasPrefix(path, "file://") || strings.HasPrefix(path, "data:,")
 }
 
 func expandDataURL(pathURL string) (string, string, error) {
@@ -326,7 +326,8 @@ func jsonnetReader(vm *jsonnet.VM, path string, opts acquire.ReadOptions) ([]run
 		return nil, err
 	}
 
-	log.Debugf("jsonnet result is: %s", jsonstr)
+	// The result is not logged, as it can contain secrets, e.g. decrypted with decryptSops.
+	log.Debugf("jsonnet result of %s is %d bytes", foundAt, len(jsonstr))
 
 	if opts.ReadTwice {
 		str2, err := vm.EvaluateSnippet(foundAt, content)
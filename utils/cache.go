@@ -0,0 +1,304 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of entries stored in the DiskCache index.
+const (
+	CacheKindHTTP = "http"
+	CacheKindOCI  = "oci"
+	// CacheKindGit entries map a git repository and ref to a commit; the repository
+	// itself is cloned under git/, one directory per commit.
+	CacheKindGit = "git"
+)
+
+// errOffline is returned when an import would require network access
+// but kubecfg runs with --offline.
+var errOffline = errors.New("not available in the local cache and --offline is set")
+
+// CacheEntry maps a remote location (an URL, an OCI reference, ...) to the digest of the
+// content it resolved to the last time it was fetched.
+type CacheEntry struct {
+	Kind         string    `json:"kind"`
+	Key          string    `json:"key"`
+	Digest       string    `json:"digest"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// DiskCache is a content-addressed cache for remote imports.
+//
+// Blobs are stored under blobs/sha256/<hex> and are never modified once written.
+// The index/<kind>/ directory maps remote locations to blob digests, together with
+// the information required to revalidate them (e.g. HTTP ETag).
+type DiskCache struct {
+	Dir string
+}
+
+// NewDiskCache returns a DiskCache rooted at dir.
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{Dir: dir}
+}
+
+// DefaultCacheDir returns the per-user default location of the kubecfg cache.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kubecfg"), nil
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (c *DiskCache) blobPath(digest string) (string, error) {
+	algo, hexsum, ok := strings.Cut(digest, ":")
+	if !ok || algo != "sha256" || len(hexsum) != sha256.Size*2 {
+		return "", fmt.Errorf("unsupported digest %q", digest)
+	}
+	if _, err := hex.DecodeString(hexsum); err != nil {
+		return "", fmt.Errorf("unsupported digest %q", digest)
+	}
+	return filepath.Join(c.Dir, "blobs", algo, hexsum), nil
+}
+
+func (c *DiskCache) entryPath(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, "index", kind, hex.EncodeToString(sum[:])+".json")
+}
+
+// GetBlob returns the content stored under digest or an error satisfying
+// errors.Is(err, fs.ErrNotExist) if it's not cached.
+func (c *DiskCache) GetBlob(digest string) ([]byte, error) {
+	path, err := c.blobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// PutBlob stores b and returns its digest.
+func (c *DiskCache) PutBlob(b []byte) (string, error) {
+	return c.putBlob(digestOf(b), b)
+}
+
+// putBlob stores b under an externally provided digest. This is used for content whose digest
+// has been computed by somebody else (e.g. an OCI registry).
+func (c *DiskCache) putBlob(digest string, b []byte) (string, error) {
+	path, err := c.blobPath(digest)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return digest, nil
+	}
+	return digest, writeFileAtomic(path, b)
+}
+
+// GetEntry returns the index entry for the (kind, key) pair, or nil if there is none.
+func (c *DiskCache) GetEntry(kind, key string) (*CacheEntry, error) {
+	b, err := os.ReadFile(c.entryPath(kind, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var e CacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, fmt.Errorf("corrupted cache entry for %q: %w", key, err)
+	}
+	return &e, nil
+}
+
+// PutEntry adds or replaces an index entry.
+func (c *DiskCache) PutEntry(e CacheEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.entryPath(e.Kind, e.Key), b)
+}
+
+// Entries returns all index entries, sorted by kind and key.
+func (c *DiskCache) Entries() ([]CacheEntry, error) {
+	var res []CacheEntry
+	err := filepath.WalkDir(filepath.Join(c.Dir, "index"), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e CacheEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("corrupted cache entry %q: %w", path, err)
+		}
+		res = append(res, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind < res[j].Kind
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+// Prune removes the index entries that have been fetched before olderThan ago
+// and then removes all the blobs no longer referenced by any entry.
+// A zero olderThan removes everything.
+// Blobs that are referenced indirectly (e.g. the layers of an OCI manifest) are
+// kept as long as the manifest blob is referenced.
+func (c *DiskCache) Prune(olderThan time.Duration) (entries int, blobs int, err error) {
+	all, err := c.Entries()
+	if err != nil {
+		return 0, 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+
+	live := map[string]bool{}
+	for _, e := range all {
+		if olderThan == 0 || e.Fetched.Before(cutoff) {
+			if err := os.Remove(c.entryPath(e.Kind, e.Key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return entries, blobs, err
+			}
+			entries++
+			continue
+		}
+		live[e.Digest] = true
+		if e.Kind == CacheKindOCI {
+			for _, d := range c.referencedBlobs(e.Digest) {
+				live[d] = true
+			}
+		}
+	}
+
+	if err := c.pruneGitClones(live, olderThan, cutoff); err != nil {
+		return entries, blobs, err
+	}
+
+	blobDir := filepath.Join(c.Dir, "blobs", "sha256")
+	files, err := os.ReadDir(blobDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, blobs, nil
+	} else if err != nil {
+		return entries, blobs, err
+	}
+	for _, f := range files {
+		if live["sha256:"+f.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(blobDir, f.Name())); err != nil {
+			return entries, blobs, err
+		}
+		blobs++
+	}
+	return entries, blobs, nil
+}
+
+// pruneGitClones removes the repositories cloned by git+ imports that are not referenced by
+// any live entry and have not been used since cutoff.
+func (c *DiskCache) pruneGitClones(live map[string]bool, olderThan time.Duration, cutoff time.Time) error {
+	clones, err := filepath.Glob(filepath.Join(c.Dir, CacheKindGit, "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range clones {
+		if live[filepath.Base(dir)] {
+			continue
+		}
+		if fi, err := os.Stat(dir); err == nil && olderThan != 0 && !fi.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// referencedBlobs returns the config and layer digests of a cached OCI manifest.
+func (c *DiskCache) referencedBlobs(manifestDigest string) []string {
+	b, err := c.GetBlob(manifestDigest)
+	if err != nil {
+		return nil
+	}
+	var m struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	res := []string{m.Config.Digest}
+	for _, l := range m.Layers {
+		res = append(res, l.Digest)
+	}
+	return res
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and then renames it,
+// so that concurrent kubecfg runs never observe partially written files.
+func writeFileAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
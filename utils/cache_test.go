@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheBlobs(t *testing.T) {
+	c := NewDiskCache(t.TempDir())
+
+	digest, err := c.PutBlob([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := digest, "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	b, err := c.GetBlob(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "hello"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	if _, err := c.GetBlob("sha256:0000000000000000000000000000000000000000000000000000000000000000"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error, got: %v", err)
+	}
+	if _, err := c.GetBlob("sha256:../../etc/passwd"); err == nil {
+		t.Errorf("expected error for malformed digest")
+	}
+}
+
+func TestDiskCachePrune(t *testing.T) {
+	c := NewDiskCache(t.TempDir())
+
+	oldDigest, _ := c.PutBlob([]byte("old"))
+	newDigest, _ := c.PutBlob([]byte("new"))
+	if _, err := c.PutBlob([]byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []CacheEntry{
+		{Kind: CacheKindHTTP, Key: "https://example.com/old.libsonnet", Digest: oldDigest, Fetched: time.Now().Add(-48 * time.Hour)},
+		{Kind: CacheKindHTTP, Key: "https://example.com/new.libsonnet", Digest: newDigest, Fetched: time.Now()},
+	} {
+		if err := c.PutEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, blobs, err := c.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != 1 || blobs != 2 {
+		t.Errorf("got %d entries and %d blobs removed, want 1 and 2", entries, blobs)
+	}
+
+	all, err := c.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Key != "https://example.com/new.libsonnet" {
+		t.Errorf("unexpected entries after prune: %v", all)
+	}
+	if _, err := c.GetBlob(newDigest); err != nil {
+		t.Errorf("live blob has been pruned: %v", err)
+	}
+
+	if _, _, err := c.Prune(0); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := c.Entries(); len(all) != 0 {
+		t.Errorf("expected empty cache, got %v", all)
+	}
+}
+
+func TestImporterDiskCache(t *testing.T) {
+	const body = "{ hello: 'world' }"
+	var requests, revalidated atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/lib.libsonnet" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := NewDiskCache(t.TempDir())
+	url := srv.URL + "/lib.libsonnet"
+
+	for i := 0; i < 2; i++ {
+		importer := MakeUniversalImporter(nil, false, WithDiskCache(cache))
+		c, _, err := importer.Import("", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := c.String(), body; got != want {
+			t.Errorf("got: %q, want: %q", got, want)
+		}
+	}
+	if got, want := revalidated.Load(), int32(1); got != want {
+		t.Errorf("got %d revalidations, want %d", got, want)
+	}
+
+	before := requests.Load()
+	offline := MakeUniversalImporter(nil, false, WithDiskCache(cache), WithOffline(true))
+	c, _, err := offline.Import("", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), body; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	_, _, err = offline.Import("", srv.URL+"/other.libsonnet")
+	if err == nil || !strings.Contains(err.Error(), "--offline") {
+		t.Errorf("expected offline error, got: %v", err)
+	}
+	if got := requests.Load(); got != before {
+		t.Errorf("offline importer made %d network requests", got-before)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+)
+
+// The modes of a ConfigHasher.
+const (
+	// ConfigHashAnnotation sets the hash of the ConfigMaps and Secrets used by
+	// pod templates as an annotation of the templates.
+	ConfigHashAnnotation = "annotation"
+	// ConfigHashSuffix appends the hash of ConfigMaps and Secrets used by pods
+	// to their names, and rewrites the references to them.
+	ConfigHashSuffix = "suffix"
+)
+
+// AnnotationConfigHash is the pod template annotation holding the hash of the
+// ConfigMaps and Secrets used by the pods, in the ConfigHashAnnotation mode.
+const AnnotationConfigHash = "kubecfg.github.com/config-hash"
+
+// configHashSuffixLen is the number of hex digits of the hash appended to names.
+const configHashSuffixLen = 10
+
+// ConfigHasher makes workloads roll out when the ConfigMaps and Secrets they use change.
+// The ConfigMaps and Secrets are the ones of the rendered objects, referenced by PodSpecs
+// through volumes, envFrom, env valueFrom and imagePullSecrets.
+type ConfigHasher struct {
+	disco     discovery.OpenAPISchemaInterface
+	mode      string
+	namespace string
+}
+
+// NewConfigHasher returns a ConfigHasher in mode ConfigHashAnnotation or ConfigHashSuffix.
+// Objects without a namespace are matched as if they were in namespace, where they are created.
+// disco, if not nil, serves the schema locating the pod templates of custom workloads.
+func NewConfigHasher(disco discovery.OpenAPISchemaInterface, mode, namespace string) (*ConfigHasher, error) {
+	if mode != ConfigHashAnnotation && mode != ConfigHashSuffix {
+		return nil, fmt.Errorf("unknown config hash mode %q, expected %q or %q", mode, ConfigHashAnnotation, ConfigHashSuffix)
+	}
+	return &ConfigHasher{disco: disco, mode: mode, namespace: namespace}, nil
+}
+
+// namespaceOf returns the namespace obj is created in.
+func (h *ConfigHasher) namespaceOf(obj *unstructured.Unstructured) string {
+	if ns := obj.GetNamespace(); ns != "" {
+		return ns
+	}
+	return h.namespace
+}
+
+// configKey identifies a ConfigMap or Secret.
+type configKey struct {
+	kind, namespace, name string
+}
+
+// configRef is a field of a PodSpec holding the name of a ConfigMap or Secret.
+type configRef struct {
+	kind  string
+	obj   map[string]interface{}
+	field string
+}
+
+// HashObjects updates objs in place.
+func (h *ConfigHasher) HashObjects(objs []*unstructured.Unstructured) error {
+	configs := map[configKey]*unstructured.Unstructured{}
+	hashes := map[configKey]string{}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != "" || (gvk.Kind != "ConfigMap" && gvk.Kind != "Secret") {
+			continue
+		}
+		key := configKey{gvk.Kind, h.namespaceOf(obj), obj.GetName()}
+		hash, err := configHash(obj)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err)
+		}
+		configs[key], hashes[key] = obj, hash
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+
+	renamed := map[configKey]string{}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		paths, err := podSpecPaths(h.disco, gvk)
+		if err != nil {
+			log.Warnf("Not hashing the configuration of %s %s: cannot find its schema: %v", gvk.Kind, obj.GetName(), err)
+			continue
+		}
+		for _, path := range paths {
+			// The pod template holding the PodSpec, if any, e.g. spec.template of a Deployment.
+			templatePath := path[:len(path)-1]
+			if len(templatePath) == 0 && h.mode == ConfigHashAnnotation {
+				// Pods don't roll out.
+				continue
+			}
+			err := visitPath(obj.Object, templatePath, func(v interface{}) error {
+				template, ok := v.(map[string]interface{})
+				if !ok {
+					return nil
+				}
+				spec, ok := template[path[len(path)-1]].(map[string]interface{})
+				if !ok {
+					return nil
+				}
+
+				used := map[configKey]bool{}
+				for _, ref := range podSpecConfigRefs(spec) {
+					name, _ := ref.obj[ref.field].(string)
+					key := configKey{ref.kind, h.namespaceOf(obj), name}
+					if _, ok := configs[key]; !ok {
+						continue
+					}
+					used[key] = true
+					if h.mode == ConfigHashSuffix {
+						newName := name + "-" + hashes[key][:configHashSuffixLen]
+						ref.obj[ref.field] = newName
+						renamed[key] = newName
+					}
+				}
+				if h.mode == ConfigHashAnnotation && len(used) > 0 {
+					setPodTemplateAnnotation(template, AnnotationConfigHash, combinedConfigHash(used, hashes))
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err)
+			}
+		}
+	}
+
+	for key, newName := range renamed {
+		configs[key].SetName(newName)
+	}
+	return nil
+}
+
+// configHash returns the hex encoded hash of the content of a ConfigMap or Secret.
+func configHash(obj *unstructured.Unstructured) (string, error) {
+	content := map[string]interface{}{}
+	for _, field := range []string{"data", "binaryData", "stringData", "type"} {
+		if v, ok := obj.Object[field]; ok {
+			content[field] = v
+		}
+	}
+	// encoding/json sorts the keys of maps, so equal contents hash alike.
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// combinedConfigHash returns the hash of the hashes of the used configs.
+func combinedConfigHash(used map[configKey]bool, hashes map[configKey]string) string {
+	lines := make([]string, 0, len(used))
+	for key := range used {
+		lines = append(lines, fmt.Sprintf("%s/%s=%s\n", key.kind, key.name, hashes[key]))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+func setPodTemplateAnnotation(template map[string]interface{}, key, value string) {
+	metadata, _ := template["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		template["metadata"] = metadata
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+	annotations[key] = value
+}
+
+// podSpecConfigRefs returns the fields of spec referencing ConfigMaps and Secrets.
+func podSpecConfigRefs(spec map[string]interface{}) []configRef {
+	var refs []configRef
+	ref := func(kind string, v interface{}, field string) {
+		if obj, ok := v.(map[string]interface{}); ok {
+			if _, ok := obj[field].(string); ok {
+				refs = append(refs, configRef{kind, obj, field})
+			}
+		}
+	}
+
+	each := func(v interface{}, path []string, fn func(map[string]interface{})) {
+		visitPath(v, path, func(v interface{}) error {
+			if obj, ok := v.(map[string]interface{}); ok {
+				fn(obj)
+			}
+			return nil
+		})
+	}
+
+	each(spec, []string{"volumes", "*"}, func(volume map[string]interface{}) {
+		ref("ConfigMap", volume["configMap"], "name")
+		ref("Secret", volume["secret"], "secretName")
+		each(volume, []string{"projected", "sources", "*"}, func(source map[string]interface{}) {
+			ref("ConfigMap", source["configMap"], "name")
+			ref("Secret", source["secret"], "name")
+		})
+	})
+	for _, field := range podSpecContainerFields {
+		each(spec, []string{field, "*"}, func(container map[string]interface{}) {
+			each(container, []string{"envFrom", "*"}, func(envFrom map[string]interface{}) {
+				ref("ConfigMap", envFrom["configMapRef"], "name")
+				ref("Secret", envFrom["secretRef"], "name")
+			})
+			each(container, []string{"env", "*", "valueFrom"}, func(valueFrom map[string]interface{}) {
+				ref("ConfigMap", valueFrom["configMapKeyRef"], "name")
+				ref("Secret", valueFrom["secretKeyRef"], "name")
+			})
+		})
+	}
+	each(spec, []string{"imagePullSecrets", "*"}, func(pullSecret map[string]interface{}) {
+		ref("Secret", pullSecret, "name")
+	})
+	return refs
+}
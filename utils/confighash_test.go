@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func configHashTestObjects(password string) []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "app-config", "namespace": "prod"},
+			"data":       map[string]interface{}{"log-level": "info"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "db", "namespace": "prod"},
+			"stringData": map[string]interface{}{"password": password},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "prod"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"volumes": []interface{}{
+							map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "app-config"}},
+							map[string]interface{}{"name": "other", "configMap": map[string]interface{}{"name": "not-rendered"}},
+						},
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name": "app",
+								"env": []interface{}{
+									map[string]interface{}{
+										"name":      "PASSWORD",
+										"valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "db", "key": "password"}},
+									},
+								},
+							},
+						},
+						"imagePullSecrets": []interface{}{map[string]interface{}{"name": "db"}},
+					},
+				},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "CronJob",
+			"metadata":   map[string]interface{}{"name": "backup", "namespace": "prod"},
+			"spec": map[string]interface{}{
+				"jobTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"metadata": map[string]interface{}{"annotations": map[string]interface{}{"owner": "dba"}},
+							"spec": map[string]interface{}{
+								"initContainers": []interface{}{
+									map[string]interface{}{
+										"name":    "init",
+										"envFrom": []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": "db"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   map[string]interface{}{"name": "debug", "namespace": "prod"},
+			"spec": map[string]interface{}{
+				"volumes": []interface{}{
+					map[string]interface{}{"name": "config", "projected": map[string]interface{}{
+						"sources": []interface{}{map[string]interface{}{"configMap": map[string]interface{}{"name": "app-config"}}},
+					}},
+				},
+			},
+		}},
+		// Same name, other namespace.
+		{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "dev"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"volumes": []interface{}{
+							map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "app-config"}},
+						},
+					},
+				},
+			},
+		}},
+		// Without a namespace, in the default namespace of the hasher.
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "web-config"},
+			"data":       map[string]interface{}{"port": "80"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"volumes": []interface{}{
+							map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "web-config"}},
+						},
+					},
+				},
+			},
+		}},
+	}
+}
+
+func hashObjects(t *testing.T, mode, password string) []*unstructured.Unstructured {
+	t.Helper()
+	h, err := NewConfigHasher(nil, mode, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := configHashTestObjects(password)
+	if err := h.HashObjects(objs); err != nil {
+		t.Fatal(err)
+	}
+	return objs
+}
+
+func TestConfigHashAnnotation(t *testing.T) {
+	deploymentHash := func(objs []*unstructured.Unstructured) string {
+		hash, _, _ := unstructured.NestedString(objs[2].Object, "spec", "template", "metadata", "annotations", AnnotationConfigHash)
+		return hash
+	}
+
+	objs := hashObjects(t, ConfigHashAnnotation, "hunter2")
+	deployment, _, _ := unstructured.NestedStringMap(objs[2].Object, "spec", "template", "metadata", "annotations")
+	cronJob, _, _ := unstructured.NestedStringMap(objs[3].Object, "spec", "jobTemplate", "spec", "template", "metadata", "annotations")
+	if deployment[AnnotationConfigHash] == "" || cronJob[AnnotationConfigHash] == "" {
+		t.Fatalf("missing config hash annotations: %v, %v", deployment, cronJob)
+	}
+	if cronJob["owner"] != "dba" {
+		t.Errorf("existing annotations were lost: %v", cronJob)
+	}
+	if deployment[AnnotationConfigHash] == cronJob[AnnotationConfigHash] {
+		t.Errorf("workloads using different configs have the same hash")
+	}
+	if _, found, _ := unstructured.NestedMap(objs[4].Object, "metadata", "annotations"); found {
+		t.Errorf("pods should not be annotated")
+	}
+	if _, found, _ := unstructured.NestedMap(objs[5].Object, "spec", "template", "metadata"); found {
+		t.Errorf("configs of other namespaces should not be used")
+	}
+	if _, found, _ := unstructured.NestedMap(objs[7].Object, "spec", "template", "metadata"); !found {
+		t.Errorf("configs without a namespace should be used in the default namespace")
+	}
+
+	// The hashes are stable, and change with the content.
+	if again := hashObjects(t, ConfigHashAnnotation, "hunter2"); deploymentHash(again) != deploymentHash(objs) {
+		t.Errorf("hash is not stable: %q != %q", deploymentHash(again), deploymentHash(objs))
+	}
+	if changed := hashObjects(t, ConfigHashAnnotation, "hunter3"); deploymentHash(changed) == deploymentHash(objs) {
+		t.Errorf("hash did not change with the Secret")
+	}
+}
+
+func TestConfigHashSuffix(t *testing.T) {
+	objs := hashObjects(t, ConfigHashSuffix, "hunter2")
+
+	configMap, secret := objs[0].GetName(), objs[1].GetName()
+	if len(configMap) != len("app-config-")+configHashSuffixLen || configMap[:len("app-config-")] != "app-config-" {
+		t.Fatalf("unexpected ConfigMap name %q", configMap)
+	}
+	if len(secret) != len("db-")+configHashSuffixLen {
+		t.Fatalf("unexpected Secret name %q", secret)
+	}
+	if objs[6].GetName() == "web-config" {
+		t.Errorf("ConfigMap without a namespace was not renamed")
+	}
+
+	refs := func(obj *unstructured.Unstructured, path ...string) []string {
+		spec, _, _ := unstructured.NestedMap(obj.Object, path...)
+		var names []string
+		for _, ref := range podSpecConfigRefs(spec) {
+			names = append(names, ref.obj[ref.field].(string))
+		}
+		return names
+	}
+	testCases := []struct {
+		obj  *unstructured.Unstructured
+		path []string
+		want []string
+	}{
+		{objs[2], []string{"spec", "template", "spec"}, []string{configMap, "not-rendered", secret, secret}},
+		{objs[3], []string{"spec", "jobTemplate", "spec", "template", "spec"}, []string{secret}},
+		{objs[4], []string{"spec"}, []string{configMap}},
+		{objs[5], []string{"spec", "template", "spec"}, []string{"app-config"}},
+		{objs[7], []string{"spec", "template", "spec"}, []string{objs[6].GetName()}},
+	}
+	for _, tc := range testCases {
+		if got := refs(tc.obj, tc.path...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %s: got %q, want %q", tc.obj.GetKind(), tc.obj.GetNamespace(), got, tc.want)
+		}
+	}
+
+	if _, err := NewConfigHasher(nil, "prefix", "default"); err == nil {
+		t.Errorf("expected error with an unknown mode")
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Signatures are stored the way cosign stores key-based signatures, so that bundles
+// signed by kubecfg can be verified with `cosign verify --key` and vice versa:
+// the signature of the manifest with digest sha256:<hex> is an OCI artifact tagged
+// sha256-<hex>.sig in the same repository, whose layers are "simple signing" payloads
+// with the base64 encoded signature in an annotation.
+const (
+	CosignSimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	CosignSignatureAnnotation    = "dev.cosignproject.cosign/signature"
+
+	cosignSignatureType = "cosign container image signature"
+
+	// CosignPasswordEnv holds the password of encrypted private keys.
+	CosignPasswordEnv = "COSIGN_PASSWORD"
+)
+
+// SimpleSigningPayload is the signed payload, binding a repository to a manifest digest.
+type SimpleSigningPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// NewSimpleSigningPayload returns the payload to be signed for the manifest dgst in repository repo.
+func NewSimpleSigningPayload(repo string, dgst digest.Digest) ([]byte, error) {
+	var p SimpleSigningPayload
+	p.Critical.Identity.DockerReference = repo
+	p.Critical.Image.DockerManifestDigest = dgst.String()
+	p.Critical.Type = cosignSignatureType
+	return json.Marshal(p)
+}
+
+// SignatureTag returns the tag of the signature artifact of the manifest dgst.
+func SignatureTag(dgst digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Encoded())
+}
+
+// OCIRepository strips the tag and the digest from an OCI reference.
+func OCIRepository(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// sameOCIRepository reports whether a and b name the same repository, e.g. "ubuntu" and
+// "index.docker.io/library/ubuntu", as cosign writes normalized names.
+func sameOCIRepository(a, b string) bool {
+	ra, errA := name.NewRepository(a)
+	rb, errB := name.NewRepository(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ra.Name() == rb.Name()
+}
+
+// LoadSigningKey reads a PEM encoded private key. Keys generated by `cosign generate-key-pair`
+// are decrypted with the password in $COSIGN_PASSWORD; unencrypted PKCS#8 and EC keys are accepted too.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "ENCRYPTED SIGSTORE PRIVATE KEY", "ENCRYPTED COSIGN PRIVATE KEY":
+		der, err := decryptCosignKey(block.Bytes, []byte(os.Getenv(CosignPasswordEnv)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key)
+	}
+	return signer, nil
+}
+
+// encryptedKey is the format of cosign encrypted private keys.
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func decryptCosignKey(b, password []byte) ([]byte, error) {
+	var k encryptedKey
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, fmt.Errorf("parsing encrypted key: %w", err)
+	}
+	if k.KDF.Name != "scrypt" || k.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported key encryption %s/%s", k.KDF.Name, k.Cipher.Name)
+	}
+	if len(k.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid nonce length %d", len(k.Cipher.Nonce))
+	}
+	secret, err := scrypt.Key(password, k.KDF.Salt, k.KDF.Params.N, k.KDF.Params.R, k.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		key   [32]byte
+		nonce [24]byte
+	)
+	copy(key[:], secret)
+	copy(nonce[:], k.Cipher.Nonce)
+	der, ok := secretbox.Open(nil, k.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, fmt.Errorf("cannot decrypt key: wrong password? (set with $%s)", CosignPasswordEnv)
+	}
+	return der, nil
+}
+
+// LoadPublicKeys reads PEM encoded public keys, such as the cosign.pub files generated by cosign.
+func LoadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(b)
+		if block == nil || block.Type != "PUBLIC KEY" {
+			return nil, fmt.Errorf("%s: no PEM encoded public key found", path)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SignPayload signs payload with key and returns the base64 encoded signature.
+// ECDSA and RSA keys sign the SHA-256 digest of the payload, Ed25519 keys sign the payload itself.
+func SignPayload(key crypto.Signer, payload []byte) (string, error) {
+	var (
+		sig []byte
+		err error
+	)
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, err = key.Sign(rand.Reader, payload, crypto.Hash(0))
+	} else {
+		h := sha256.Sum256(payload)
+		sig, err = key.Sign(rand.Reader, h[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifyPayload checks the base64 encoded signature of payload against key.
+func VerifyPayload(key crypto.PublicKey, payload []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+	h := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, h[:], sig) {
+			return errInvalidSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errInvalidSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// WithTrustedKeys requires OCI bundles to be signed by one of the given keys.
+// Verification is disabled if no key is given.
+func WithTrustedKeys(keys []crypto.PublicKey) ImporterOption {
+	return func(importer *universalImporter) {
+		importer.trustedKeys = keys
+	}
+}
+
+// verifySignature checks that the manifest manifestDesc of the package referenced by remote has been
+// signed by one of the trusted keys.
+func (o *ociImporter) verifySignature(ctx context.Context, remote *ociRemote, manifestDesc ocispec.Descriptor) error {
+	repo := OCIRepository(remote.pkg)
+	sigRemote := &ociRemote{importer: o, pkg: repo + ":" + SignatureTag(manifestDesc.Digest)}
+
+	sigDesc, err := o.resolveManifest(ctx, sigRemote)
+	if err != nil {
+		return fmt.Errorf("verifying %q: cannot find signature %s: %w", remote.pkg, sigRemote.pkg, err)
+	}
+	var sigManifest ocispec.Manifest
+	if err := o.fetchInto(ctx, sigRemote, sigDesc, &sigManifest); err != nil {
+		return fmt.Errorf("verifying %q: %w", remote.pkg, err)
+	}
+
+	for _, l := range sigManifest.Layers {
+		signature, found := l.Annotations[CosignSignatureAnnotation]
+		if l.MediaType != CosignSimpleSigningMediaType || !found {
+			continue
+		}
+		payload, err := o.fetchBlob(ctx, sigRemote, l)
+		if err != nil {
+			return fmt.Errorf("verifying %q: %w", remote.pkg, err)
+		}
+		var p SimpleSigningPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			log.Debugf("Skipping signature layer %s of %q: %v", l.Digest, sigRemote.pkg, err)
+			continue
+		}
+		if p.Critical.Image.DockerManifestDigest != manifestDesc.Digest.String() {
+			log.Debugf("Skipping signature layer %s of %q: signs %s", l.Digest, sigRemote.pkg, p.Critical.Image.DockerManifestDigest)
+			continue
+		}
+		// Otherwise a bundle signed for another repository could be served in place of this one.
+		// OCI image layouts are local files without a repository name, like local imports.
+		if !o.layout && !sameOCIRepository(p.Critical.Identity.DockerReference, repo) {
+			log.Debugf("Skipping signature layer %s of %q: signs repository %q", l.Digest, sigRemote.pkg, p.Critical.Identity.DockerReference)
+			continue
+		}
+		for _, key := range o.trustedKeys {
+			if err := VerifyPayload(key, payload, signature); err == nil {
+				log.Debugf("Verified signature of %q (%s)", remote.pkg, manifestDesc.Digest)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("verifying %q: no valid signature of %s by a trusted key", remote.pkg, manifestDesc.Digest)
+}
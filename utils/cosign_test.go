@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// writeEncryptedKey writes key in the format of `cosign generate-key-pair`.
+func writeEncryptedKey(t *testing.T, key crypto.Signer, password string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k encryptedKey
+	k.KDF.Name = "scrypt"
+	k.KDF.Params.N, k.KDF.Params.R, k.KDF.Params.P = 1024, 8, 1
+	k.KDF.Salt = make([]byte, 32)
+	rand.Read(k.KDF.Salt)
+	k.Cipher.Name = "nacl/secretbox"
+	k.Cipher.Nonce = make([]byte, 24)
+	rand.Read(k.Cipher.Nonce)
+
+	secret, err := scrypt.Key([]byte(password), k.KDF.Salt, 1024, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		sk    [32]byte
+		nonce [24]byte
+	)
+	copy(sk[:], secret)
+	copy(nonce[:], k.Cipher.Nonce)
+	k.Ciphertext = secretbox.Seal(nil, der, &nonce, &sk)
+
+	b, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.key")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: b}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeEncryptedKey(t, key, "s3cret")
+
+	t.Setenv(CosignPasswordEnv, "wrong")
+	if _, err := LoadSigningKey(path); err == nil {
+		t.Error("expected error with wrong password")
+	}
+
+	t.Setenv(CosignPasswordEnv, "s3cret")
+	signer, err := LoadSigningKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(signer) {
+		t.Error("decrypted key doesn't match")
+	}
+}
+
+func TestSignVerifyPayload(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	payload, err := NewSimpleSigningPayload("ghcr.io/example/bundle", digest.FromString("manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []crypto.Signer{ecKey, rsaKey, edKey} {
+		sig, err := SignPayload(key, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyPayload(key.Public(), payload, sig); err != nil {
+			t.Errorf("%T: %v", key, err)
+		}
+		if err := VerifyPayload(key.Public(), append(payload, ' '), sig); err == nil {
+			t.Errorf("%T: tampered payload verified", key)
+		}
+		if err := VerifyPayload(other.Public(), payload, sig); err == nil {
+			t.Errorf("%T: verified with the wrong key", key)
+		}
+	}
+}
+
+func TestOCIRepository(t *testing.T) {
+	for ref, want := range map[string]string{
+		"ghcr.io/example/bundle:v1":                  "ghcr.io/example/bundle",
+		"localhost:5000/bundle:v1":                   "localhost:5000/bundle",
+		"localhost:5000/bundle":                      "localhost:5000/bundle",
+		"ghcr.io/example/bundle@" + digestOf(nil):    "ghcr.io/example/bundle",
+		"ghcr.io/example/bundle:v1@" + digestOf(nil): "ghcr.io/example/bundle",
+	} {
+		if got := OCIRepository(ref); got != want {
+			t.Errorf("%s: got %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestSameOCIRepository(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want bool
+	}{
+		{"ghcr.io/example/bundle", "ghcr.io/example/bundle", true},
+		{"ubuntu", "index.docker.io/library/ubuntu", true},
+		{"docker.io/example/bundle", "index.docker.io/example/bundle", true},
+		{"ghcr.io/example/bundle", "ghcr.io/example/other", false},
+		{"ghcr.io/example/bundle", "ghcr.io/other/bundle", false},
+		{"localhost:5000/bundle", "localhost:5001/bundle", false},
+	}
+	for _, tc := range testCases {
+		if got := sameOCIRepository(tc.a, tc.b); got != tc.want {
+			t.Errorf("%s, %s: got %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/ini.v1"
+)
+
+// parseToml parses a TOML document. Dates and times are returned as RFC 3339 strings.
+func parseToml(data string) (interface{}, error) {
+	var res map[string]interface{}
+	if _, err := toml.Decode(data, &res); err != nil {
+		return nil, err
+	}
+	return tomlToJsonnet(res), nil
+}
+
+func tomlToJsonnet(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = tomlToJsonnet(e)
+		}
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = tomlToJsonnet(e)
+		}
+		return res
+	case []interface{}:
+		for i, e := range v {
+			v[i] = tomlToJsonnet(e)
+		}
+	case time.Time:
+		// Local dates and times are decoded in zones with these names.
+		switch v.Location().String() {
+		case "date-local":
+			return v.Format("2006-01-02")
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return jsonnetValue(v)
+}
+
+// manifestToml encodes an object as a TOML document, with sorted keys.
+func manifestToml(v interface{}) (string, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected an object, got %T", v)
+	}
+	value, err := tomlValue(obj, "")
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// tomlValue converts the integral numbers of v to integers, so that they are not encoded
+// as floats, and rejects nulls, which TOML can't represent.
+func tomlValue(v interface{}, path string) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("%s: TOML has no null value", strings.TrimPrefix(path, "."))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), nil
+		}
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if res[k], err = tomlValue(e, path+"."+k); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if res[i], err = tomlValue(e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+// parseIni parses an INI document. The keys before the first section are at the top level,
+// along with an object for each section. All the values are strings.
+func parseIni(data string) (interface{}, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{SpaceBeforeInlineComment: true}, []byte(data))
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]interface{}{}
+	for _, section := range f.Sections() {
+		values := res
+		if name := section.Name(); name != ini.DefaultSection {
+			if _, ok := res[name]; ok {
+				return nil, fmt.Errorf("section %q conflicts with a key of the default section", name)
+			}
+			values = map[string]interface{}{}
+			res[name] = values
+		}
+		for _, key := range section.Keys() {
+			if _, ok := values[key.Name()].(map[string]interface{}); ok {
+				return nil, fmt.Errorf("key %q of the default section conflicts with a section", key.Name())
+			}
+			values[key.Name()] = key.Value()
+		}
+	}
+	return res, nil
+}
+
+// manifestIni encodes an object as an INI document, with sorted keys. Its scalar fields are
+// written before the first section, and its object fields are sections.
+func manifestIni(v interface{}) (string, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected an object, got %T", v)
+	}
+	f := ini.Empty()
+	var sections []string
+	for _, k := range sortedKeys(obj) {
+		if _, ok := obj[k].(map[string]interface{}); ok {
+			sections = append(sections, k)
+			continue
+		}
+		if err := setIniKey(f.Section(ini.DefaultSection), k, obj[k]); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range sections {
+		section, err := f.NewSection(name)
+		if err != nil {
+			return "", err
+		}
+		values := obj[name].(map[string]interface{})
+		for _, k := range sortedKeys(values) {
+			if err := setIniKey(section, k, values[k]); err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func setIniKey(section *ini.Section, key string, v interface{}) error {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case bool:
+		s = strconv.FormatBool(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		s = ""
+	default:
+		return fmt.Errorf("%s: INI values must be strings, numbers or booleans, got %T", key, v)
+	}
+	_, err := section.NewKey(key, s)
+	return err
+}
+
+// parseHcl parses an HCL (version 1) document, like the configuration of Vault or Consul.
+// Blocks are decoded as arrays of objects.
+func parseHcl(data string) (interface{}, error) {
+	var res map[string]interface{}
+	if err := hcl.Unmarshal([]byte(data), &res); err != nil {
+		return nil, err
+	}
+	return jsonnetValue(hclToJsonnet(res)), nil
+}
+
+func hclToJsonnet(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = hclToJsonnet(e)
+		}
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = hclToJsonnet(e)
+		}
+		return res
+	case []interface{}:
+		for i, e := range v {
+			v[i] = hclToJsonnet(e)
+		}
+	}
+	return v
+}
+
+// parseCsv parses CSV records. If header is true, the first record holds the names of the
+// fields, and the records are returned as objects.
+func parseCsv(data string, header bool) (interface{}, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	if !header {
+		for _, record := range records {
+			fields := make([]interface{}, len(record))
+			for i, f := range record {
+				fields[i] = f
+			}
+			res = append(res, fields)
+		}
+		return res, nil
+	}
+	if len(records) == 0 {
+		return res, nil
+	}
+	names := records[0]
+	for _, record := range records[1:] {
+		obj := make(map[string]interface{}, len(names))
+		for i, name := range names {
+			obj[name] = record[i]
+		}
+		res = append(res, obj)
+	}
+	return res, nil
+}
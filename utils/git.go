@@ -0,0 +1,323 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	log "github.com/sirupsen/logrus"
+)
+
+// gitImportSchemes are the URL schemes served by the gitImporter.
+var gitImportSchemes = []string{"git+https", "git+http", "git+ssh", "git+file"}
+
+func isGitImportScheme(scheme string) bool {
+	for _, s := range gitImportSchemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+var gitCommitRE = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// gitImporter satisfies the http.RoundTripper interface and serves
+// `import 'git+https://github.com/org/repo.git//path/file.libsonnet?ref=v1.2.3'` statements.
+//
+// The part before the double slash is the repository URL (any transport supported by go-git,
+// e.g. git+https, git+ssh or git+file) and the part after it is the path inside the repository.
+// The optional ref parameter is a tag, branch or full commit hash; the default is the remote HEAD.
+type gitImporter struct {
+	diskCache *DiskCache
+	offline   bool
+	lock      *Lockfile
+
+	commits map[string]plumbing.Hash
+	repos   map[string]*git.Repository
+}
+
+func newGitImporter() *gitImporter {
+	return &gitImporter{
+		commits: map[string]plumbing.Hash{},
+		repos:   map[string]*git.Repository{},
+	}
+}
+
+// gitImportURL is the parsed form of a git+ import URL.
+type gitImportURL struct {
+	Repo string
+	Path string
+	Ref  string
+}
+
+func parseGitImportURL(u *url.URL) (gitImportURL, error) {
+	repoPath, path, ok := strings.Cut(u.Path, "//")
+	if !ok {
+		return gitImportURL{}, fmt.Errorf("git import %q must separate the repository from the path inside it with '//'", u.Redacted())
+	}
+	repo := url.URL{
+		Scheme: strings.TrimPrefix(u.Scheme, "git+"),
+		User:   u.User,
+		Host:   u.Host,
+		Path:   repoPath,
+	}
+	return gitImportURL{Repo: repo.String(), Path: path, Ref: u.Query().Get("ref")}, nil
+}
+
+// lockKey identifies a (repository, ref) pair without leaking credentials.
+func (g gitImportURL) lockKey() string {
+	u, err := url.Parse(g.Repo)
+	if err == nil {
+		u.User = nil
+		return fmt.Sprintf("git+%s?ref=%s", u, g.Ref)
+	}
+	return fmt.Sprintf("git+%s?ref=%s", g.Repo, g.Ref)
+}
+
+func (g *gitImporter) RoundTrip(req *http.Request) (*http.Response, error) {
+	gu, err := parseGitImportURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := g.resolveRef(gu)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %q in %s: %w", gu.Ref, gu.lockKey(), err)
+	}
+	if err := g.lock.CheckImport(gu.lockKey(), commit.String()); err != nil {
+		return nil, err
+	}
+
+	repo, err := g.open(gu, commit)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", gu.lockKey(), err)
+	}
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.File(gu.Path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return simpleHTTPResponse(req, http.StatusNotFound, io.NopCloser(strings.NewReader(""))), nil
+	} else if err != nil {
+		return nil, err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	return simpleHTTPResponse(req, http.StatusOK, r), nil
+}
+
+// resolveRef returns the commit the ref points to. Refs are resolved once per run, so
+// that all the files imported from a repository come from the same commit.
+func (g *gitImporter) resolveRef(gu gitImportURL) (plumbing.Hash, error) {
+	if gitCommitRE.MatchString(gu.Ref) {
+		return plumbing.NewHash(gu.Ref), nil
+	}
+	key := gu.lockKey()
+	if h, ok := g.commits[key]; ok {
+		return h, nil
+	}
+
+	if g.offline {
+		if g.diskCache != nil {
+			entry, err := g.diskCache.GetEntry(CacheKindGit, key)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			if entry != nil {
+				h := plumbing.NewHash(entry.Digest)
+				g.commits[key] = h
+				return h, nil
+			}
+		}
+		return plumbing.ZeroHash, errOffline
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{gu.Repo}})
+	refs, err := remote.List(&git.ListOptions{PeelingOption: git.AppendPeeled})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, r := range refs {
+		byName[r.Name()] = r
+	}
+
+	var candidates []plumbing.ReferenceName
+	if gu.Ref == "" {
+		candidates = []plumbing.ReferenceName{plumbing.HEAD}
+	} else {
+		candidates = []plumbing.ReferenceName{
+			// annotated tags are listed twice; the peeled one points to the commit.
+			plumbing.ReferenceName("refs/tags/" + gu.Ref + "^{}"),
+			plumbing.NewTagReferenceName(gu.Ref),
+			plumbing.NewBranchReferenceName(gu.Ref),
+			plumbing.ReferenceName(gu.Ref),
+		}
+	}
+	for _, name := range candidates {
+		r, ok := byName[name]
+		// follow symbolic references, like HEAD -> refs/heads/main
+		for i := 0; ok && r.Type() == plumbing.SymbolicReference && i < 10; i++ {
+			r, ok = byName[r.Target()]
+		}
+		if !ok {
+			continue
+		}
+		h := r.Hash()
+		g.commits[key] = h
+		if g.diskCache != nil {
+			err := g.diskCache.PutEntry(CacheEntry{Kind: CacheKindGit, Key: key, Digest: h.String(), Fetched: time.Now()})
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+		return h, nil
+	}
+	return plumbing.ZeroHash, fmt.Errorf("no such tag or branch")
+}
+
+// open returns a repository containing commit. Repositories are shallow clones; when the disk
+// cache is enabled they are stored in it, one per commit.
+func (g *gitImporter) open(gu gitImportURL, commit plumbing.Hash) (*git.Repository, error) {
+	repoKey := gu.lockKey()
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(repoKey, "?ref="+gu.Ref)))
+	memoKey := hex.EncodeToString(sum[:]) + "/" + commit.String()
+	if repo, ok := g.repos[memoKey]; ok {
+		return repo, nil
+	}
+
+	var dir string
+	if g.diskCache != nil {
+		dir = filepath.Join(g.diskCache.Dir, CacheKindGit, filepath.FromSlash(memoKey))
+		if repo, err := git.PlainOpen(dir); err == nil {
+			if _, err := repo.CommitObject(commit); err == nil {
+				g.repos[memoKey] = repo
+				return repo, nil
+			}
+		}
+	}
+	if g.offline {
+		return nil, errOffline
+	}
+
+	var (
+		repo *git.Repository
+		err  error
+	)
+	if dir == "" {
+		repo, err = git.Init(memory.NewStorage(), nil)
+		if err == nil {
+			err = fetchGitCommit(repo, gu, commit)
+		}
+	} else {
+		repo, err = g.cloneToDir(dir, gu, commit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.repos[memoKey] = repo
+	return repo, nil
+}
+
+// cloneToDir fetches the commit into a temporary bare repository and then renames it to dir,
+// so that concurrent kubecfg runs never see half-populated repositories.
+func (g *gitImporter) cloneToDir(dir string, gu gitImportURL, commit plumbing.Hash) (*git.Repository, error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	repo, err := git.PlainInit(tmp, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := fetchGitCommit(repo, gu, commit); err != nil {
+		return nil, err
+	}
+	os.RemoveAll(dir)
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+	return git.PlainOpen(dir)
+}
+
+// fetchGitCommit performs a shallow fetch of the given commit into repo.
+// Fetching by commit hash requires server support; if it fails we fall back to
+// fetching the ref, and finally to a full fetch.
+func fetchGitCommit(repo *git.Repository, gu gitImportURL, commit plumbing.Hash) error {
+	remote, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{gu.Repo}})
+	if err != nil {
+		return err
+	}
+
+	const target = "refs/kubecfg/import"
+	var attempts []git.FetchOptions
+	if gu.Ref != "" && !gitCommitRE.MatchString(gu.Ref) {
+		for _, name := range []plumbing.ReferenceName{plumbing.NewTagReferenceName(gu.Ref), plumbing.NewBranchReferenceName(gu.Ref)} {
+			attempts = append(attempts, git.FetchOptions{
+				RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", name, target))},
+				Depth:    1,
+				Tags:     git.NoTags,
+			})
+		}
+	}
+	attempts = append(attempts,
+		git.FetchOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", commit, target))},
+			Depth:    1,
+			Tags:     git.NoTags,
+		},
+		git.FetchOptions{
+			RefSpecs: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"},
+		},
+	)
+
+	for _, opts := range attempts {
+		err = remote.Fetch(&opts)
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			log.Debugf("git fetch %v from %s: %v", opts.RefSpecs, gu.lockKey(), err)
+			continue
+		}
+		if _, err = repo.CommitObject(commit); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot fetch commit %s: %w", commit, err)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+// makeGitRepo creates a bare repository with a v1 tag and a newer commit on the default branch.
+func makeGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack not available")
+	}
+
+	work := t.TempDir()
+	repo, err := git.PlainInit(work, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)}
+	commit := func(files map[string]string) plumbing.Hash {
+		for name, content := range files {
+			path := filepath.Join(work, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		h, err := wt.Commit("commit", &git.CommitOptions{Author: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	v1 := commit(map[string]string{
+		"lib/main.libsonnet":    "(import 'version.libsonnet') + { helper: import '../util/helper.libsonnet' }",
+		"lib/version.libsonnet": "{ version: 1 }",
+		"util/helper.libsonnet": "'help'",
+	})
+	if _, err := repo.CreateTag("v1", v1, &git.CreateTagOptions{Tagger: sig, Message: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	commit(map[string]string{"lib/version.libsonnet": "{ version: 2 }"})
+
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	if _, err := git.PlainClone(bare, true, &git.CloneOptions{URL: work}); err != nil {
+		t.Fatal(err)
+	}
+	return bare
+}
+
+func TestGitImporter(t *testing.T) {
+	repo := makeGitRepo(t)
+	cache := NewDiskCache(t.TempDir())
+
+	vm := jsonnet.MakeVM()
+	vm.Importer(MakeUniversalImporter(nil, false, WithDiskCache(cache)))
+
+	testCases := []struct {
+		ref  string
+		want string
+	}{
+		{"?ref=v1", `{"helper":"help","version":1}`},
+		{"?ref=master", `{"helper":"help","version":2}`},
+		{"", `{"helper":"help","version":2}`},
+	}
+	for _, tc := range testCases {
+		snippet := "import 'git+file://" + repo + "//lib/main.libsonnet" + tc.ref + "'"
+		out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", snippet)
+		if err != nil {
+			t.Fatalf("ref %q: %v", tc.ref, err)
+		}
+		if got := strings.Join(strings.Fields(out), ""); got != tc.want {
+			t.Errorf("ref %q: got: %s, want: %s", tc.ref, got, tc.want)
+		}
+	}
+
+	_, _, err := MakeUniversalImporter(nil, false).Import("", "git+file://"+repo+"//lib/missing.libsonnet?ref=v1")
+	if err == nil || !strings.Contains(err.Error(), "Couldn't open import") {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+
+	// Cached clones and resolved refs must be enough to work offline.
+	if err := os.RemoveAll(repo); err != nil {
+		t.Fatal(err)
+	}
+	offline := MakeUniversalImporter(nil, false, WithDiskCache(cache), WithOffline(true))
+	c, _, err := offline.Import("", "git+file://"+repo+"//lib/version.libsonnet?ref=v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), "{ version: 1 }"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// goTemplateOptions are the options of renderGoTemplate.
+type goTemplateOptions struct {
+	// strict makes referencing a missing map key an error, instead of rendering "<no value>".
+	strict bool
+	// leftDelim and rightDelim replace "{{" and "}}", if not empty.
+	leftDelim, rightDelim string
+}
+
+func parseGoTemplateOptions(v interface{}) (goTemplateOptions, error) {
+	var opts goTemplateOptions
+	if v == nil {
+		return opts, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return opts, fmt.Errorf("options must be an object, got %T", v)
+	}
+	for k, v := range obj {
+		switch k {
+		case "strict":
+			if opts.strict, ok = v.(bool); !ok {
+				return opts, fmt.Errorf("options.strict must be a boolean")
+			}
+		case "delims":
+			delims, ok := v.([]interface{})
+			if !ok || len(delims) != 2 {
+				return opts, fmt.Errorf("options.delims must be an array of two strings")
+			}
+			left, ok1 := delims[0].(string)
+			right, ok2 := delims[1].(string)
+			if !ok1 || !ok2 || left == "" || right == "" {
+				return opts, fmt.Errorf("options.delims must be an array of two non-empty strings")
+			}
+			opts.leftDelim, opts.rightDelim = left, right
+		default:
+			return opts, fmt.Errorf("unknown option %q, expected \"strict\" or \"delims\"", k)
+		}
+	}
+	return opts, nil
+}
+
+// goTemplateNondeterministicFuncs are the sprig functions returning random values that
+// sprig.HermeticTxtFuncMap keeps.
+var goTemplateNondeterministicFuncs = []string{
+	"randInt", "shuffle", "bcrypt", "htpasswd", "encryptAES", "genPrivateKey",
+	"genCA", "genCAWithKey", "genSelfSignedCert", "genSelfSignedCertWithKey", "genSignedCert", "genSignedCertWithKey",
+}
+
+// goTemplateFuncs returns the sprig functions that always return the same result for the same
+// arguments: the ones reading the environment, the time or random values would make the
+// output of kubecfg change between runs.
+func goTemplateFuncs() template.FuncMap {
+	funcs := sprig.HermeticTxtFuncMap()
+	for _, name := range goTemplateNondeterministicFuncs {
+		delete(funcs, name)
+	}
+	return funcs
+}
+
+// renderGoTemplate renders the text/template text with data, with the functions of goTemplateFuncs.
+func renderGoTemplate(text string, data interface{}, opts goTemplateOptions) (string, error) {
+	tmpl := template.New("").Funcs(goTemplateFuncs())
+	if opts.leftDelim != "" {
+		tmpl = tmpl.Delims(opts.leftDelim, opts.rightDelim)
+	}
+	if opts.strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+	tmpl, err := tmpl.Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPCredential holds the credentials used for HTTP(S) imports from a host.
+type HTTPCredential struct {
+	// Host is a hostname, optionally followed by a port.
+	Host string `json:"host" mapstructure:"host"`
+	// Token is sent as a bearer token.
+	Token string `json:"token,omitempty" mapstructure:"token"`
+	// TokenEnv is the name of an environment variable containing the bearer token, so that
+	// the token doesn't need to be stored in the project file.
+	TokenEnv string `json:"tokenEnv,omitempty" mapstructure:"tokenEnv"`
+	// Username and Password are sent with basic authentication.
+	Username    string `json:"username,omitempty" mapstructure:"username"`
+	Password    string `json:"password,omitempty" mapstructure:"password"`
+	PasswordEnv string `json:"passwordEnv,omitempty" mapstructure:"passwordEnv"`
+	// Headers are added to every request (e.g. "PRIVATE-TOKEN" for GitLab or "X-JFrog-Art-Api" for Artifactory).
+	Headers map[string]string `json:"headers,omitempty" mapstructure:"headers"`
+}
+
+func (c *HTTPCredential) apply(req *http.Request) {
+	token := c.Token
+	if c.TokenEnv != "" {
+		token = os.Getenv(c.TokenEnv)
+	}
+	password := c.Password
+	if c.PasswordEnv != "" {
+		password = os.Getenv(c.PasswordEnv)
+	}
+
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.Username != "" || password != "":
+		req.SetBasicAuth(c.Username, password)
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// HTTPAuth provides the per-host credentials for HTTP(S) imports. They are looked up in order:
+//
+//   - the KUBECFG_HTTP_TOKEN_<HOST>, KUBECFG_HTTP_USERNAME_<HOST>, KUBECFG_HTTP_PASSWORD_<HOST> and
+//     KUBECFG_HTTP_HEADERS_<HOST> environment variables, where <HOST> is the host name
+//     (optionally followed by the port) in upper case with all the other characters replaced by '_'.
+//     KUBECFG_HTTP_HEADERS_<HOST> contains one "Name: value" header per line.
+//   - the configured credentials (e.g. from the project file).
+//   - the netrc file.
+type HTTPAuth struct {
+	Credentials []HTTPCredential
+	Netrc       []HTTPCredential
+}
+
+// NewHTTPAuth returns an HTTPAuth using the given credentials and the netrc file at netrcPath, if it exists.
+func NewHTTPAuth(credentials []HTTPCredential, netrcPath string) (*HTTPAuth, error) {
+	a := &HTTPAuth{Credentials: credentials}
+	if netrcPath != "" {
+		b, err := os.ReadFile(netrcPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		a.Netrc = parseNetrc(string(b))
+	}
+	return a, nil
+}
+
+// DefaultNetrcPath returns the path of the netrc file: $NETRC if set, ~/.netrc otherwise.
+func DefaultNetrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// WithHTTPAuth authenticates HTTP(S) imports.
+func WithHTTPAuth(a *HTTPAuth) ImporterOption {
+	return func(importer *universalImporter) {
+		importer.auth = a
+	}
+}
+
+// lookup returns the credentials for the host of u (in host or host:port form), or nil.
+func (a *HTTPAuth) lookup(u *url.URL) (*HTTPCredential, string) {
+	if a == nil {
+		return nil, ""
+	}
+	hosts := []string{u.Host}
+	if u.Hostname() != u.Host {
+		hosts = append(hosts, u.Hostname())
+	}
+
+	for _, h := range hosts {
+		if c := credentialFromEnv(h); c != nil {
+			return c, "environment"
+		}
+	}
+	for _, source := range []struct {
+		name  string
+		creds []HTTPCredential
+	}{{"project config", a.Credentials}, {"netrc", a.Netrc}} {
+		for _, h := range hosts {
+			for i := range source.creds {
+				if source.creds[i].Host == h {
+					return &source.creds[i], source.name
+				}
+			}
+		}
+	}
+	for i := range a.Netrc {
+		if a.Netrc[i].Host == "" {
+			return &a.Netrc[i], "netrc default"
+		}
+	}
+	return nil, ""
+}
+
+func envHostKey(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, host)
+}
+
+func credentialFromEnv(host string) *HTTPCredential {
+	key := envHostKey(host)
+	c := HTTPCredential{
+		Host:     host,
+		Token:    os.Getenv("KUBECFG_HTTP_TOKEN_" + key),
+		Username: os.Getenv("KUBECFG_HTTP_USERNAME_" + key),
+		Password: os.Getenv("KUBECFG_HTTP_PASSWORD_" + key),
+	}
+	for _, line := range strings.Split(os.Getenv("KUBECFG_HTTP_HEADERS_"+key), "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			if c.Headers == nil {
+				c.Headers = map[string]string{}
+			}
+			c.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if c.Token == "" && c.Username == "" && c.Password == "" && len(c.Headers) == 0 {
+		return nil
+	}
+	return &c
+}
+
+// parseNetrc parses the content of a netrc file. The "default" entry has an empty Host.
+// Macro definitions are skipped.
+func parseNetrc(s string) []HTTPCredential {
+	var (
+		res     []HTTPCredential
+		current *HTTPCredential
+	)
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+			switch fields[j] {
+			case "machine":
+				res = append(res, HTTPCredential{Host: next()})
+				current = &res[len(res)-1]
+			case "default":
+				res = append(res, HTTPCredential{})
+				current = &res[len(res)-1]
+			case "login":
+				if current != nil {
+					current.Username = next()
+				}
+			case "password":
+				if current != nil {
+					current.Password = next()
+				}
+			case "account":
+				next()
+			case "macdef":
+				// a macro definition ends at the first empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	return res
+}
+
+// authTransport adds the configured credentials to HTTP(S) requests.
+type authTransport struct {
+	base http.RoundTripper
+	auth *HTTPAuth
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" || req.URL.User != nil {
+		return t.base.RoundTrip(req)
+	}
+	c, source := t.auth.lookup(req.URL)
+	if c == nil {
+		return t.base.RoundTrip(req)
+	}
+	if req.URL.Scheme == "http" {
+		log.Warnf("Sending credentials for %s over plain HTTP", req.URL.Host)
+	}
+	log.Debugf("Using credentials from %s for %s", source, req.URL.Host)
+	req = req.Clone(req.Context())
+	c.apply(req)
+	return t.base.RoundTrip(req)
+}
+
+// userinfoRE matches the userinfo of URLs that url.Parse rejects.
+var userinfoRE = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://)[^/?#]*@`)
+
+// RedactURL removes the credentials from an URL, so that it can be logged or shown in error messages.
+// The whole userinfo is removed, as tokens are often passed as the user name (https://TOKEN@host/...).
+// Strings that are not URLs are returned unchanged.
+func RedactURL(s string) string {
+	if _, err := url.Parse(s); err != nil {
+		return userinfoRE.ReplaceAllString(s, "$1")
+	}
+	return stripUserinfo(s)
+}
+
+// stripUserinfo removes the credentials from an URL, to get a key suitable for caches and lockfiles.
+func stripUserinfo(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	u.User = nil
+	return u.String()
+}
@@ -1,9 +1,9 @@
 package utils
 
 import (
+	"crypto"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -42,7 +42,16 @@ A real-world example:
     will be resolved as https://raw.githubusercontent.com/ksonnet/ksonnet-lib/master/ksonnet.beta.2/k8s.libsonnet
     and downloaded from that location.
 */
-func MakeUniversalImporter(searchURLs []*url.URL, alpha bool) jsonnet.Importer {
+func MakeUniversalImporter(searchURLs []*url.URL, alpha bool, opts ...ImporterOption) jsonnet.Importer {
+	importer := &universalImporter{
+		BaseSearchURLs: searchURLs,
+		cache:          map[string]jsonnet.Contents{},
+		alpha:          alpha,
+	}
+	for _, o := range opts {
+		o(importer)
+	}
+
 	// Reconstructed copy of http.DefaultTransport (to avoid
 	// modifying the default)
 	t := &http.Transport{
@@ -60,14 +69,68 @@ func MakeUniversalImporter(searchURLs []*url.URL, alpha bool) jsonnet.Importer {
 
 	t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
 	t.RegisterProtocol("internal", http.NewFileTransport(newInternalFS()))
-	t.RegisterProtocol("oci", newOCIImporter())
+	oci := newOCIImporter()
+	oci.diskCache = importer.diskCache
+	oci.offline = importer.offline
+	oci.lock = importer.lock
+	oci.policy = importer.policy
+	oci.trustedKeys = importer.trustedKeys
+	t.RegisterProtocol("oci", oci)
+	layout := newOCILayoutImporter()
+	layout.lock = importer.lock
+	layout.policy = importer.policy
+	layout.trustedKeys = importer.trustedKeys
+	t.RegisterProtocol(OCILayoutScheme, layout)
 	t.RegisterProtocol("kustomize+https", &kustomizeImporter{alpha: alpha})
+	gi := newGitImporter()
+	gi.diskCache = importer.diskCache
+	gi.offline = importer.offline
+	gi.lock = importer.lock
+	for _, scheme := range gitImportSchemes {
+		t.RegisterProtocol(scheme, gi)
+	}
 
-	return &universalImporter{
-		BaseSearchURLs: searchURLs,
-		HTTPClient:     &http.Client{Transport: t},
-		cache:          map[string]jsonnet.Contents{},
-		alpha:          alpha,
+	var transport http.RoundTripper = t
+	if importer.auth != nil {
+		transport = &authTransport{base: t, auth: importer.auth}
+	}
+	importer.HTTPClient = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if err := importer.policy.Check(req.URL); err != nil {
+				return fmt.Errorf("redirect to %s denied by import policy: %w", req.URL.Redacted(), err)
+			}
+			return nil
+		},
+	}
+	return importer
+}
+
+// ImporterOption configures optional features of the importer returned by MakeUniversalImporter.
+type ImporterOption func(*universalImporter)
+
+// WithDiskCache makes the importer store HTTP(S) imports and OCI bundles in a persistent cache.
+func WithDiskCache(c *DiskCache) ImporterOption {
+	return func(importer *universalImporter) {
+		importer.diskCache = c
+	}
+}
+
+// WithOffline prevents the importer from accessing the network.
+// Remote imports are served only from the disk cache.
+func WithOffline(offline bool) ImporterOption {
+	return func(importer *universalImporter) {
+		importer.offline = offline
+	}
+}
+
+// WithLockfile checks the content of every remote import against the digests pinned in the lockfile.
+func WithLockfile(l *Lockfile) ImporterOption {
+	return func(importer *universalImporter) {
+		importer.lock = l
 	}
 }
 
@@ -76,10 +139,16 @@ type universalImporter struct {
 	HTTPClient     *http.Client
 	cache          map[string]jsonnet.Contents
 	alpha          bool // alpha features are enable only if true
+	diskCache      *DiskCache
+	offline        bool
+	lock           *Lockfile
+	policy         *ImportPolicy
+	auth           *HTTPAuth
+	trustedKeys    []crypto.PublicKey
 }
 
 func (importer *universalImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
-	log.Debugf("Importing %q from %q", importedPath, importedFrom)
+	log.Debugf("Importing %q from %q", RedactURL(importedPath), RedactURL(importedFrom))
 
 	binary := false
 	if strings.HasPrefix(importedPath, "binary://") {
@@ -94,12 +163,12 @@ func (importer *universalImporter) Import(importedFrom, importedPath string) (js
 
 	candidateURLs, err := importer.expandImportToCandidateURLs(importedFrom, importedPath)
 	if err != nil {
-		return jsonnet.Contents{}, "", fmt.Errorf("Could not get candidate URLs for when importing %s (imported from %s): %v", importedPath, importedFrom, err)
+		return jsonnet.Contents{}, "", fmt.Errorf("Could not get candidate URLs for when importing %s (imported from %s): %v", RedactURL(importedPath), RedactURL(importedFrom), err)
 	}
 
 	var tried []string
 	for _, u := range candidateURLs {
-		if u.Scheme == "oci" {
+		if u.Scheme == "oci" || u.Scheme == OCILayoutScheme {
 			u = normalizeOCIURL(u)
 		}
 
@@ -112,7 +181,13 @@ func (importer *universalImporter) Import(importedFrom, importedPath string) (js
 			return c, foundAt, nil
 		}
 
-		tried = append(tried, foundAt)
+		if err := importer.policy.Check(u); err != nil {
+			log.Debugf("Import of %q denied by import policy: %v", u.Redacted(), err)
+			tried = append(tried, fmt.Sprintf("%s (denied by import policy: %v)", RedactURL(foundAt), err))
+			continue
+		}
+
+		tried = append(tried, RedactURL(foundAt))
 		importedData, err := importer.tryImport(foundAt, binary)
 		if err == nil {
 			importer.cache[foundAt] = importedData
@@ -122,34 +197,153 @@ func (importer *universalImporter) Import(importedFrom, importedPath string) (js
 		}
 	}
 
-	return jsonnet.Contents{}, "", fmt.Errorf("Couldn't open import %q, no match locally or in library search paths. Tried: %s",
-		importedPath,
+	var offlineHint string
+	if importer.offline {
+		offlineHint = " (remote locations are looked up only in the local cache because --offline is set)"
+	}
+	return jsonnet.Contents{}, "", fmt.Errorf("Couldn't open import %q, no match locally or in library search paths%s. Tried: %s",
+		RedactURL(importedPath),
+		offlineHint,
 		strings.Join(tried, ";"),
 	)
 }
 
 func (importer *universalImporter) tryImport(url string, binary bool) (jsonnet.Contents, error) {
 	url = strings.TrimSuffix(url, "##binaryImport")
-	res, err := importer.HTTPClient.Get(url)
+
+	var (
+		bodyBytes []byte
+		err       error
+	)
+	if isHTTPURL(url) {
+		bodyBytes, err = importer.fetchHTTP(url)
+	} else {
+		bodyBytes, err = importer.fetch(url)
+	}
 	if err != nil {
 		return jsonnet.Contents{}, err
 	}
+
+	// oci:// imports are pinned by the ociImporter, which knows the manifest digest.
+	if isHTTPURL(url) || strings.HasPrefix(url, "kustomize+https://") {
+		if err := importer.lock.CheckImport(stripUserinfo(url), digestOf(bodyBytes)); err != nil {
+			return jsonnet.Contents{}, err
+		}
+	}
+
+	if binary {
+		return toIntArray(bodyBytes), nil
+	}
+	return jsonnet.MakeContents(string(bodyBytes)), nil
+}
+
+func isHTTPURL(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
+func (importer *universalImporter) fetch(url string) ([]byte, error) {
+	res, err := importer.HTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
 	defer res.Body.Close()
-	log.Debugf("GET %q -> %s", url, res.Status)
+	log.Debugf("GET %q -> %s", RedactURL(url), res.Status)
 	if res.StatusCode == http.StatusNotFound {
-		return jsonnet.Contents{}, errNotFound
+		return nil, errNotFound
 	} else if res.StatusCode != http.StatusOK {
-		return jsonnet.Contents{}, fmt.Errorf("error reading content: %s", res.Status)
+		return nil, fmt.Errorf("error reading content: %s", res.Status)
 	}
 
-	bodyBytes, err := ioutil.ReadAll(res.Body)
+	return importer.policy.readAll(res.Body)
+}
+
+// fetchHTTP fetches an HTTP(S) import going through the disk cache, if configured.
+// Cached content is revalidated using the ETag and Last-Modified headers returned by the server.
+func (importer *universalImporter) fetchHTTP(url string) ([]byte, error) {
+	var (
+		entry  *CacheEntry
+		cached []byte
+	)
+	if importer.diskCache != nil {
+		var err error
+		entry, err = importer.diskCache.GetEntry(CacheKindHTTP, stripUserinfo(url))
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			cached, err = importer.diskCache.GetBlob(entry.Digest)
+			if err != nil {
+				log.Debugf("Ignoring cache entry for %q: %v", RedactURL(url), err)
+				entry = nil
+			}
+		}
+	}
+
+	if importer.offline {
+		if entry == nil {
+			log.Debugf("GET %q: %v", RedactURL(url), errOffline)
+			return nil, errNotFound
+		}
+		log.Debugf("GET %q -> served from cache (offline)", RedactURL(url))
+		return cached, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return jsonnet.Contents{}, err
+		return nil, err
 	}
-	if binary {
-		return toIntArray(bodyBytes), nil
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
 	}
-	return jsonnet.MakeContents(string(bodyBytes)), nil
+
+	res, err := importer.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	log.Debugf("GET %q -> %s", RedactURL(url), res.Status)
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && entry != nil:
+		entry.Fetched = time.Now()
+		if err := importer.diskCache.PutEntry(*entry); err != nil {
+			return nil, err
+		}
+		return cached, nil
+	case res.StatusCode == http.StatusNotFound:
+		return nil, errNotFound
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("error reading content: %s", res.Status)
+	}
+
+	bodyBytes, err := importer.policy.readAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if importer.diskCache != nil {
+		digest, err := importer.diskCache.PutBlob(bodyBytes)
+		if err != nil {
+			return nil, err
+		}
+		err = importer.diskCache.PutEntry(CacheEntry{
+			Kind:         CacheKindHTTP,
+			Key:          stripUserinfo(url),
+			Digest:       digest,
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			Fetched:      time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bodyBytes, nil
 }
 
 func toIntArray(bytes []byte) jsonnet.Contents {
@@ -182,6 +376,10 @@ func (importer *universalImporter) expandImportToCandidateURLs(importedFrom, imp
 
 	candidateURLs := make([]*url.URL, 1, len(importer.BaseSearchURLs)+1)
 	candidateURLs[0] = importDirURL.ResolveReference(importedPathURL)
+	// Relative imports inside a git repository come from the same ref as the importing file.
+	if isGitImportScheme(importDirURL.Scheme) && importedPathURL.RawQuery == "" {
+		candidateURLs[0].RawQuery = importDirURL.RawQuery
+	}
 
 	for _, u := range importer.BaseSearchURLs {
 		candidateURLs = append(candidateURLs, u.ResolveReference(importedPathURL))
@@ -0,0 +1,267 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Files and directories used by jsonnet-bundler (https://github.com/jsonnet-bundler/jsonnet-bundler).
+const (
+	JsonnetfileName     = "jsonnetfile.json"
+	JsonnetfileLockName = "jsonnetfile.lock.json"
+	JsonnetVendorDir    = "vendor"
+)
+
+// Jsonnetfile is the content of a jsonnetfile.json or jsonnetfile.lock.json file.
+type Jsonnetfile struct {
+	Version      int                     `json:"version"`
+	Dependencies []JsonnetfileDependency `json:"dependencies"`
+	// LegacyImports makes the dependencies importable also by their short name (e.g. 'ksonnet-util/kausal.libsonnet').
+	LegacyImports bool `json:"legacyImports"`
+}
+
+// JsonnetfileDependency is a single library listed in a Jsonnetfile.
+type JsonnetfileDependency struct {
+	Source JsonnetfileSource `json:"source"`
+	// Version is a tag, branch or commit in jsonnetfile.json and always a commit in the lock file.
+	Version string `json:"version"`
+	// Sum is the checksum of the vendored files; only present in the lock file.
+	Sum string `json:"sum,omitempty"`
+	// Name overrides the legacy name of the dependency.
+	Name string `json:"name,omitempty"`
+}
+
+// JsonnetfileSource tells where a dependency comes from. Exactly one field is set.
+type JsonnetfileSource struct {
+	Git   *JsonnetfileGitSource   `json:"git,omitempty"`
+	Local *JsonnetfileLocalSource `json:"local,omitempty"`
+}
+
+// JsonnetfileGitSource is a directory inside a git repository.
+type JsonnetfileGitSource struct {
+	Remote string `json:"remote"`
+	Subdir string `json:"subdir"`
+}
+
+// JsonnetfileLocalSource is a directory on the local filesystem, relative to the jsonnetfile.
+type JsonnetfileLocalSource struct {
+	Directory string `json:"directory"`
+}
+
+// LoadJsonnetfile parses a jsonnetfile.json or jsonnetfile.lock.json file.
+// The error satisfies errors.Is(err, fs.ErrNotExist) if the file doesn't exist.
+func LoadJsonnetfile(path string) (*Jsonnetfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// like jsonnet-bundler, legacy imports are enabled unless explicitly turned off.
+	jf := &Jsonnetfile{LegacyImports: true}
+	if err := json.Unmarshal(b, jf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, d := range jf.Dependencies {
+		if (d.Source.Git == nil) == (d.Source.Local == nil) {
+			return nil, fmt.Errorf("%s: each dependency must have exactly one of the git or local sources", path)
+		}
+		// The name is the path of a symlink in the vendor directory.
+		if d.Name != "" && (d.Name == "." || d.Name == ".." || strings.ContainsAny(d.Name, `/\`)) {
+			return nil, fmt.Errorf("%s: invalid dependency name %q: must be a single path element", path, d.Name)
+		}
+	}
+	return jf, nil
+}
+
+// Save writes the Jsonnetfile to path, in the same format used by jsonnet-bundler.
+func (jf *Jsonnetfile) Save(path string) error {
+	b, err := json.MarshalIndent(jf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// FindVendorDir returns the vendor directory belonging to the closest jsonnetfile.json found
+// in dir or in one of its parents, if any.
+func FindVendorDir(dir string) (string, bool, error) {
+	path, found, err := SearchUp(JsonnetfileName, filepath.Join(dir, JsonnetfileName))
+	if err != nil || !found {
+		return "", false, err
+	}
+	return filepath.Join(filepath.Dir(path), JsonnetVendorDir), true, nil
+}
+
+var scpLikeGitURLRE = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// InstallPath returns where the dependency is stored, relative to the vendor directory.
+// Git dependencies are stored under host/path/subdir (e.g. github.com/grafana/jsonnet-libs/grafonnet).
+func (d JsonnetfileDependency) InstallPath() string {
+	if d.Source.Local != nil {
+		return d.LegacyName()
+	}
+	remote := d.Source.Git.Remote
+	var host, repoPath string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" {
+		host, repoPath = u.Hostname(), u.Path
+	} else if m := scpLikeGitURLRE.FindStringSubmatch(remote); m != nil {
+		host, repoPath = m[1], m[2]
+	} else {
+		repoPath = remote
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	return path.Join(host, repoPath, strings.Trim(d.Source.Git.Subdir, "/"))
+}
+
+// LegacyName returns the short name the dependency is also importable with when legacy imports are enabled.
+func (d JsonnetfileDependency) LegacyName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	if d.Source.Local != nil {
+		return filepath.Base(d.Source.Local.Directory)
+	}
+	if d.Source.Git.Subdir != "" {
+		return path.Base(strings.Trim(d.Source.Git.Subdir, "/"))
+	}
+	return path.Base(strings.TrimSuffix(d.InstallPath(), "/"))
+}
+
+// GitCheckout copies the subdir of the git repository at the given ref into dest and returns the commit
+// the ref resolved to. Repositories are fetched and cached like git+ imports.
+func GitCheckout(repoURL, ref, subdir, dest string, opts ...ImporterOption) (string, error) {
+	var importer universalImporter
+	for _, o := range opts {
+		o(&importer)
+	}
+	g := newGitImporter()
+	g.diskCache = importer.diskCache
+	g.offline = importer.offline
+
+	gu := gitImportURL{Repo: repoURL, Ref: ref}
+	commit, err := g.resolveRef(gu)
+	if err != nil {
+		return "", fmt.Errorf("resolving %q in %s: %w", ref, repoURL, err)
+	}
+	repo, err := g.open(gu, commit)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", repoURL, err)
+	}
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return "", err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+	if subdir = strings.Trim(subdir, "/"); subdir != "" {
+		tree, err = tree.Tree(subdir)
+		if err != nil {
+			return "", fmt.Errorf("%s at %s: %q: %w", repoURL, commit, subdir, err)
+		}
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		target := filepath.Join(dest, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if f.Mode == filemode.Symlink {
+			linkTarget, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			// Files of nested dependencies may be written through symlinks, which must
+			// therefore stay within dest.
+			resolved := path.Join(path.Dir(f.Name), linkTarget)
+			if path.IsAbs(linkTarget) || filepath.IsAbs(linkTarget) || resolved == ".." || strings.HasPrefix(resolved, "../") {
+				return fmt.Errorf("%s at %s: symlink %s points outside of the dependency: %q", repoURL, commit, f.Name, linkTarget)
+			}
+			return os.Symlink(linkTarget, target)
+		}
+		if f.Mode == filemode.Submodule {
+			return nil
+		}
+		perm := os.FileMode(0644)
+		if f.Mode == filemode.Executable {
+			perm = 0755
+		}
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		w, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	})
+	if err != nil {
+		return "", err
+	}
+	return commit.String(), nil
+}
+
+// HashDir returns the checksum jsonnet-bundler records in jsonnetfile.lock.json:
+// the base64 encoded sha256 of the content of all the files in dir, in lexical order.
+func HashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			// symlinked files are hashed like regular files; dangling symlinks and symlinks to directories are skipped.
+			if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+				return nil
+			}
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultLockfileName is the name of the file recording the pinned remote imports.
+const DefaultLockfileName = "kubecfg.lock"
+
+const lockfileVersion = 1
+
+// LockMode controls how a Lockfile treats the digests it is asked to check.
+type LockMode int
+
+const (
+	// LockEnforce fails when a digest doesn't match the pinned one, or it is not pinned at all.
+	LockEnforce LockMode = iota
+	// LockUpdate pins new digests and overwrites the existing pins that don't match.
+	LockUpdate
+	// LockRegenerate discards all the existing pins and records only the ones it sees.
+	LockRegenerate
+)
+
+// Lockfile pins remote imports to the digest of their content, so that changes upstream
+// don't silently change the rendering. New pins are kept in memory until Save is called.
+type Lockfile struct {
+	path    string
+	mode    LockMode
+	data    lockfileData
+	changed bool
+}
+
+type lockfileData struct {
+	Version int `json:"version"`
+	// Imports maps the URL of each remote import to the digest of its content
+	// (or of the OCI manifest, for oci:// imports).
+	Imports map[string]string `json:"imports"`
+	// Images maps the container images pinned by --pin-images to the digest of their manifest.
+	Images map[string]string `json:"images,omitempty"`
+	// Tags maps the images whose tag is a semver constraint, like example.com/app:~1.4,
+	// to the matching tag and its digest, like 1.4.7@sha256:...
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// LoadLockfile reads the lockfile at path.
+// In LockEnforce mode a missing lockfile disables all checks and nil is returned.
+func LoadLockfile(path string, mode LockMode) (*Lockfile, error) {
+	l := &Lockfile{
+		path: path,
+		mode: mode,
+		data: lockfileData{Version: lockfileVersion, Imports: map[string]string{}},
+	}
+
+	b, err := os.ReadFile(path)
+	if mode == LockRegenerate {
+		// Pinned images and tags are not seen by the import checks, keep them.
+		var old lockfileData
+		if err == nil && json.Unmarshal(b, &old) == nil && old.Version == lockfileVersion {
+			l.data.Images, l.data.Tags = old.Images, old.Tags
+		}
+		return l, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		if mode == LockEnforce {
+			return nil, nil
+		}
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &l.data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if l.data.Version != lockfileVersion {
+		return nil, fmt.Errorf("unsupported %s version %d", path, l.data.Version)
+	}
+	if l.data.Imports == nil {
+		l.data.Imports = map[string]string{}
+	}
+	return l, nil
+}
+
+// Path returns the location of the lockfile.
+func (l *Lockfile) Path() string {
+	return l.path
+}
+
+// CheckImport verifies that the remote import at url has the pinned digest.
+// In update modes the digest is pinned instead.
+func (l *Lockfile) CheckImport(url, digest string) error {
+	if l == nil {
+		return nil
+	}
+
+	pinned, found := l.data.Imports[url]
+	if l.mode == LockEnforce {
+		if !found {
+			return fmt.Errorf("remote import %q is not pinned in %s; run `kubecfg lock` or use --update-lock", url, l.path)
+		}
+		if pinned != digest {
+			return fmt.Errorf("integrity check failed for remote import %q: %s pins %s but got %s; use --update-lock to accept the new content", url, l.path, pinned, digest)
+		}
+		return nil
+	}
+
+	if found && pinned == digest {
+		return nil
+	}
+	l.data.Imports[url] = digest
+	l.changed = true
+	return nil
+}
+
+// PinImage returns the digest pinned for the container image. Images that are not pinned yet
+// are resolved with resolve and pinned, and so are all images in LockUpdate mode.
+func (l *Lockfile) PinImage(image string, resolve func() (string, error)) (string, error) {
+	if l == nil {
+		return resolve()
+	}
+	return l.pin(&l.data.Images, image, resolve)
+}
+
+// PinTag returns the tag and digest pinned for the image with a tag constraint, as tag@digest.
+// Like for PinImage, constraints are resolved with resolve if not pinned yet or in LockUpdate mode.
+func (l *Lockfile) PinTag(image string, resolve func() (string, error)) (string, error) {
+	if l == nil {
+		return resolve()
+	}
+	return l.pin(&l.data.Tags, image, resolve)
+}
+
+func (l *Lockfile) pin(pins *map[string]string, key string, resolve func() (string, error)) (string, error) {
+	pinned, found := (*pins)[key]
+	if found && l.mode != LockUpdate {
+		return pinned, nil
+	}
+	value, err := resolve()
+	if err != nil {
+		return "", err
+	}
+	if found && pinned == value {
+		return value, nil
+	}
+	if *pins == nil {
+		*pins = map[string]string{}
+	}
+	(*pins)[key] = value
+	l.changed = true
+	return value, nil
+}
+
+// Changed returns true if pins have been added or updated since the lockfile was loaded or saved.
+func (l *Lockfile) Changed() bool {
+	return l != nil && l.changed
+}
+
+// Save writes the lockfile to disk. Pins are sorted so that the file is stable across runs.
+func (l *Lockfile) Save() error {
+	if l == nil {
+		return nil
+	}
+	return l.save()
+}
+
+func (l *Lockfile) save() error {
+	b, err := json.MarshalIndent(l.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if dir := filepath.Dir(l.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(l.path, b, 0644); err != nil {
+		return err
+	}
+	l.changed = false
+	return nil
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockfileModes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultLockfileName)
+	const url = "https://example.com/lib.libsonnet"
+
+	l, err := LoadLockfile(path, LockEnforce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != nil {
+		t.Fatalf("missing lockfile in enforce mode should disable checks")
+	}
+	if err := l.CheckImport(url, "sha256:aaaa"); err != nil {
+		t.Errorf("nil lockfile should accept everything, got: %v", err)
+	}
+
+	l, err = LoadLockfile(path, LockUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CheckImport(url, "sha256:aaaa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("lockfile written before Save")
+	}
+	if !l.Changed() {
+		t.Errorf("new pin not reported as a change")
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = LoadLockfile(path, LockEnforce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CheckImport(url, "sha256:aaaa"); err != nil {
+		t.Errorf("pinned digest rejected: %v", err)
+	}
+	if err := l.CheckImport(url, "sha256:bbbb"); err == nil || !strings.Contains(err.Error(), "integrity check failed") {
+		t.Errorf("expected integrity error, got: %v", err)
+	}
+	if err := l.CheckImport("https://example.com/other.libsonnet", "sha256:aaaa"); err == nil || !strings.Contains(err.Error(), "not pinned") {
+		t.Errorf("expected not pinned error, got: %v", err)
+	}
+
+	l, err = LoadLockfile(path, LockRegenerate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CheckImport("https://example.com/other.libsonnet", "sha256:cccc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), url) {
+		t.Errorf("regenerated lockfile still contains stale pin: %s", b)
+	}
+}
+
+func TestImporterLockfile(t *testing.T) {
+	body := "{ a: 1 }"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	url := srv.URL + "/lib.libsonnet"
+	path := filepath.Join(t.TempDir(), DefaultLockfileName)
+
+	l, err := LoadLockfile(path, LockUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := MakeUniversalImporter(nil, false, WithLockfile(l)).Import("", url); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	body = "{ a: 2 }"
+	l, err = LoadLockfile(path, LockEnforce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = MakeUniversalImporter(nil, false, WithLockfile(l)).Import("", url)
+	if err == nil || !strings.Contains(err.Error(), "integrity check failed") {
+		t.Errorf("expected integrity error, got: %v", err)
+	}
+}
+
+func TestLockfileImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultLockfileName)
+	resolved := "sha256:aaaa"
+	resolve := func() (string, error) { return resolved, nil }
+
+	l, err := LoadLockfile(path, LockUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.PinImage("docker.io/library/nginx:1.25", resolve); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved = "sha256:bbbb"
+	for _, tc := range []struct {
+		mode LockMode
+		want string
+	}{
+		{LockEnforce, "sha256:aaaa"},
+		{LockRegenerate, "sha256:aaaa"}, // regenerating the import pins keeps the images
+		{LockUpdate, "sha256:bbbb"},
+	} {
+		l, err := LoadLockfile(path, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.mode == LockRegenerate {
+			if err := l.Save(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := l.PinImage("docker.io/library/nginx:1.25", resolve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("mode %d: got %q, want %q", tc.mode, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ImageRewriteRule rewrites image names, e.g. to pull them through a mirror.
+// Rules match the full image name, with the registry, like docker.io/library/nginx:1.25.
+// Exactly one of Prefix and Regex must be set.
+type ImageRewriteRule struct {
+	// Prefix is replaced by Replacement in the images starting with it.
+	Prefix string `json:"prefix,omitempty" mapstructure:"prefix"`
+	// Regex is replaced by Replacement, which can refer to the capture groups as $1 or ${name}.
+	Regex       string `json:"regex,omitempty" mapstructure:"regex"`
+	Replacement string `json:"replacement" mapstructure:"replacement"`
+	// AfterResolve applies the rule to the image after its digest has been resolved,
+	// rather than to the image whose digest is looked up.
+	AfterResolve bool `json:"afterResolve,omitempty" mapstructure:"afterResolve"`
+}
+
+type imageRewriter struct {
+	rule  ImageRewriteRule
+	regex *regexp.Regexp
+}
+
+func (r imageRewriter) rewrite(image string) string {
+	if r.regex != nil {
+		return r.regex.ReplaceAllString(image, r.rule.Replacement)
+	}
+	if strings.HasPrefix(image, r.rule.Prefix) {
+		return r.rule.Replacement + strings.TrimPrefix(image, r.rule.Prefix)
+	}
+	return image
+}
+
+// NewMirrorResolver returns a resolver that rewrites image names with rules, and resolves
+// the digest of the rewritten images with inner. Rules are applied in order: the ones
+// with AfterResolve set to the resolved image, the others before resolving it.
+func NewMirrorResolver(rules []ImageRewriteRule, inner Resolver) (Resolver, error) {
+	r := &mirrorResolver{inner: inner}
+	for i, rule := range rules {
+		rw := imageRewriter{rule: rule}
+		switch {
+		case (rule.Prefix == "") == (rule.Regex == ""):
+			return nil, fmt.Errorf("image rewrite rule %d: exactly one of prefix and regex must be set", i)
+		case rule.Regex != "":
+			var err error
+			if rw.regex, err = regexp.Compile(rule.Regex); err != nil {
+				return nil, fmt.Errorf("image rewrite rule %d: %w", i, err)
+			}
+		}
+		if rule.AfterResolve {
+			r.after = append(r.after, rw)
+		} else {
+			r.before = append(r.before, rw)
+		}
+	}
+	return r, nil
+}
+
+type mirrorResolver struct {
+	before []imageRewriter
+	after  []imageRewriter
+	inner  Resolver
+}
+
+func (r *mirrorResolver) Resolve(n *ImageName) error {
+	if err := rewriteImageName(n, r.before); err != nil {
+		return err
+	}
+	if err := r.inner.Resolve(n); err != nil {
+		return err
+	}
+	return rewriteImageName(n, r.after)
+}
+
+func rewriteImageName(n *ImageName, rewriters []imageRewriter) error {
+	if len(rewriters) == 0 {
+		return nil
+	}
+	image := n.String()
+	for _, rw := range rewriters {
+		image = rw.rewrite(image)
+	}
+	rewritten, err := ParseImageName(image)
+	if err != nil {
+		return fmt.Errorf("rewriting %s: %w", n, err)
+	}
+	*n = rewritten
+	return nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+)
+
+// fakeResolver resolves every image to the same digest, recording the images it was asked for.
+type fakeResolver struct {
+	digest string
+	seen   []string
+}
+
+func (r *fakeResolver) Resolve(n *ImageName) error {
+	r.seen = append(r.seen, n.String())
+	n.Digest = r.digest
+	return nil
+}
+
+func TestMirrorResolver(t *testing.T) {
+	const digest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	rules := []ImageRewriteRule{
+		{Prefix: "docker.io/library/", Replacement: "mirror.corp/dockerhub/library/"},
+		{Regex: `^quay\.io/([^/]+)/`, Replacement: "mirror.corp/quay/${1}-"},
+		{Prefix: "mirror.corp/", Replacement: "pull.mirror.corp/", AfterResolve: true},
+	}
+
+	testCases := []struct {
+		image    string
+		resolved string
+		output   string
+	}{
+		{"nginx:1.25", "mirror.corp/dockerhub/library/nginx:1.25", "pull.mirror.corp/dockerhub/library/nginx@" + digest},
+		{"quay.io/prometheus/node-exporter:v1", "mirror.corp/quay/prometheus-node-exporter:v1", "pull.mirror.corp/quay/prometheus-node-exporter@" + digest},
+		{"gcr.io/distroless/static:latest", "gcr.io/distroless/static:latest", "gcr.io/distroless/static@" + digest},
+	}
+	for _, tc := range testCases {
+		inner := &fakeResolver{digest: digest}
+		r, err := NewMirrorResolver(rules, inner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := resolveImage(r, tc.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(inner.seen) != 1 || inner.seen[0] != tc.resolved {
+			t.Errorf("%s: resolved %q, want %q", tc.image, inner.seen, tc.resolved)
+		}
+		if got != tc.output {
+			t.Errorf("%s: got %q, want %q", tc.image, got, tc.output)
+		}
+	}
+
+	for _, bad := range []ImageRewriteRule{
+		{Replacement: "x"},
+		{Prefix: "a", Regex: "b", Replacement: "x"},
+		{Regex: "(", Replacement: "x"},
+	} {
+		if _, err := NewMirrorResolver([]ImageRewriteRule{bad}, &fakeResolver{}); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -16,9 +16,14 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	gohash "hash"
 	"io"
 	"net/url"
 	"path/filepath"
@@ -30,6 +35,7 @@ import (
 	jsonnetAst "github.com/google/go-jsonnet/ast"
 	jsonschema "github.com/santhosh-tekuri/jsonschema/v5"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 	helmLoader "helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	helmEngine "helm.sh/helm/v3/pkg/engine"
@@ -37,7 +43,11 @@ import (
 )
 
 func resolveImage(resolver Resolver, image string) (string, error) {
-	n, err := ParseImageName(image)
+	parse := ParseImageName
+	if p, ok := resolver.(ImageParser); ok {
+		parse = p.ParseImage
+	}
+	n, err := parse(image)
 	if err != nil {
 		return "", err
 	}
@@ -76,8 +86,27 @@ func (r *ArrayReader) Read(p []byte) (int, error) {
 	return n, nil
 }
 
+// NativeFuncOption configures the native functions registered by RegisterNativeFuncs.
+type NativeFuncOption func(*nativeFuncOpts)
+
+type nativeFuncOpts struct {
+	sopsKeys SopsKeys
+}
+
+// WithSopsKeys sets the private keys used by decryptSops.
+func WithSopsKeys(keys SopsKeys) NativeFuncOption {
+	return func(opts *nativeFuncOpts) {
+		opts.sopsKeys = keys
+	}
+}
+
 // RegisterNativeFuncs adds kubecfg's native jsonnet functions to provided VM
-func RegisterNativeFuncs(vm *jsonnet.VM, resolver Resolver) {
+func RegisterNativeFuncs(vm *jsonnet.VM, resolver Resolver, opt ...NativeFuncOption) {
+	var opts nativeFuncOpts
+	for _, o := range opt {
+		o(&opts)
+	}
+
 	// TODO(mkm): go-jsonnet 0.12.x now contains native std.parseJson; deprecate and remove this one.
 	vm.NativeFunction(&jsonnet.NativeFunction{
 		Name:   "parseJson",
@@ -122,6 +151,123 @@ func RegisterNativeFuncs(vm *jsonnet.VM, resolver Resolver) {
 		},
 	})
 
+	for name, parse := range map[string]func(string) (interface{}, error){
+		"parseToml": parseToml,
+		"parseIni":  parseIni,
+		"parseHcl":  parseHcl,
+	} {
+		vm.NativeFunction(&jsonnet.NativeFunction{
+			Name:   name,
+			Params: []jsonnetAst.Identifier{"data"},
+			Func: func(args []interface{}) (interface{}, error) {
+				data, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("%s: expected a string", name)
+				}
+				res, err := parse(data)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				return res, nil
+			},
+		})
+	}
+
+	for name, manifest := range map[string]func(interface{}) (string, error){
+		"manifestToml": manifestToml,
+		"manifestIni":  manifestIni,
+	} {
+		vm.NativeFunction(&jsonnet.NativeFunction{
+			Name:   name,
+			Params: []jsonnetAst.Identifier{"value"},
+			Func: func(args []interface{}) (interface{}, error) {
+				res, err := manifest(args[0])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				return res, nil
+			},
+		})
+	}
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "parseCsv",
+		Params: []jsonnetAst.Identifier{"data", "header"},
+		Func: func(args []interface{}) (interface{}, error) {
+			data, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("parseCsv: expected a string")
+			}
+			header, ok := args[1].(bool)
+			if !ok {
+				return nil, fmt.Errorf("parseCsv: header must be a boolean")
+			}
+			res, err := parseCsv(data, header)
+			if err != nil {
+				return nil, fmt.Errorf("parseCsv: %w", err)
+			}
+			return res, nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "renderGoTemplate",
+		Params: []jsonnetAst.Identifier{"template", "data", "options"},
+		Func: func(args []interface{}) (interface{}, error) {
+			text, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("renderGoTemplate: template must be a string")
+			}
+			opts, err := parseGoTemplateOptions(args[2])
+			if err != nil {
+				return nil, fmt.Errorf("renderGoTemplate: %w", err)
+			}
+			res, err := renderGoTemplate(text, args[1], opts)
+			if err != nil {
+				return nil, fmt.Errorf("renderGoTemplate: %w", err)
+			}
+			return res, nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "query",
+		Params: []jsonnetAst.Identifier{"obj", "expr", "language"},
+		Func: func(args []interface{}) (interface{}, error) {
+			expr, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("query: expr must be a string")
+			}
+			language, ok := args[2].(string)
+			if !ok {
+				return nil, fmt.Errorf("query: language must be a string")
+			}
+			res, err := query(args[0], expr, language)
+			if err != nil {
+				return nil, fmt.Errorf("query: %w", err)
+			}
+			return res, nil
+		},
+	})
+
+	for name, apply := range map[string]func(interface{}, interface{}) (interface{}, error){
+		"jsonPatch":           applyJSONPatch,
+		"mergePatch":          applyMergePatch,
+		"strategicMergePatch": applyStrategicMergePatch,
+	} {
+		vm.NativeFunction(&jsonnet.NativeFunction{
+			Name:   name,
+			Params: []jsonnetAst.Identifier{"obj", "patch"},
+			Func: func(args []interface{}) (interface{}, error) {
+				res, err := apply(args[0], args[1])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				return res, nil
+			},
+		})
+	}
+
 	vm.NativeFunction(&jsonnet.NativeFunction{
 		Name:   "resolveImage",
 		Params: []jsonnetAst.Identifier{"image"},
@@ -130,6 +276,46 @@ func RegisterNativeFuncs(vm *jsonnet.VM, resolver Resolver) {
 		},
 	})
 
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "decryptSops",
+		Params: []jsonnetAst.Identifier{"data"},
+		Func: func(args []interface{}) (res interface{}, err error) {
+			data, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("decryptSops: data must be a string, e.g. importstr 'secret.enc.yaml'")
+			}
+			v, err := DecryptSops([]byte(data), opts.sopsKeys)
+			if err != nil {
+				return nil, fmt.Errorf("decryptSops: %w", err)
+			}
+			return jsonnetValue(v), nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "sealSecret",
+		Params: []jsonnetAst.Identifier{"secret", "certPem", "scope"},
+		Func: func(args []interface{}) (res interface{}, err error) {
+			secret, ok := args[0].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("sealSecret: secret must be a Secret object")
+			}
+			certPEM, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("sealSecret: certPem must be a string, e.g. importstr 'sealed-secrets.pem'")
+			}
+			scope, ok := args[2].(string)
+			if !ok {
+				return nil, fmt.Errorf("sealSecret: scope must be a string")
+			}
+			sealed, err := SealSecret(secret, certPEM, scope)
+			if err != nil {
+				return nil, fmt.Errorf("sealSecret: %w", err)
+			}
+			return sealed, nil
+		},
+	})
+
 	vm.NativeFunction(&jsonnet.NativeFunction{
 		Name:   "escapeStringRegex",
 		Params: []jsonnetAst.Identifier{"str"},
@@ -293,6 +479,110 @@ func RegisterNativeFuncs(vm *jsonnet.VM, resolver Resolver) {
 			return true, nil
 		},
 	})
+
+	for name, h := range map[string]func() gohash.Hash{"sha256": sha256.New, "sha512": sha512.New} {
+		vm.NativeFunction(&jsonnet.NativeFunction{
+			Name:   name,
+			Params: []jsonnetAst.Identifier{"data"},
+			Func: func(args []interface{}) (interface{}, error) {
+				data, err := bytesArg(args[0])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				d := h()
+				d.Write(data)
+				return hex.EncodeToString(d.Sum(nil)), nil
+			},
+		})
+
+		hmacName := "hmac" + strings.ToUpper(name[:1]) + name[1:]
+		vm.NativeFunction(&jsonnet.NativeFunction{
+			Name:   hmacName,
+			Params: []jsonnetAst.Identifier{"key", "data"},
+			Func: func(args []interface{}) (interface{}, error) {
+				key, err := bytesArg(args[0])
+				if err != nil {
+					return nil, fmt.Errorf("%s: key: %w", hmacName, err)
+				}
+				data, err := bytesArg(args[1])
+				if err != nil {
+					return nil, fmt.Errorf("%s: data: %w", hmacName, err)
+				}
+				mac := hmac.New(h, key)
+				mac.Write(data)
+				return hex.EncodeToString(mac.Sum(nil)), nil
+			},
+		})
+	}
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "hexEncode",
+		Params: []jsonnetAst.Identifier{"data"},
+		Func: func(args []interface{}) (interface{}, error) {
+			data, err := bytesArg(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("hexEncode: %w", err)
+			}
+			return hex.EncodeToString(data), nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "hexDecode",
+		Params: []jsonnetAst.Identifier{"str"},
+		Func: func(args []interface{}) (interface{}, error) {
+			str, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("hexDecode: expected a string")
+			}
+			data, err := hex.DecodeString(str)
+			if err != nil {
+				return nil, fmt.Errorf("hexDecode: %w", err)
+			}
+			return byteArray(data), nil
+		},
+	})
+
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name:   "bcrypt",
+		Params: []jsonnetAst.Identifier{"password", "cost"},
+		Func: func(args []interface{}) (interface{}, error) {
+			password, err := bytesArg(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("bcrypt: %w", err)
+			}
+			cost, ok := args[1].(float64)
+			if !ok {
+				return nil, fmt.Errorf("bcrypt: cost must be a number")
+			}
+			hashed, err := bcrypt.GenerateFromPassword(password, int(cost))
+			if err != nil {
+				return nil, fmt.Errorf("bcrypt: %w", err)
+			}
+			return string(hashed), nil
+		},
+	})
+}
+
+// bytesArg returns the bytes of a native function argument, either a string
+// or an array of numbers, like the ones returned by importbin.
+func bytesArg(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []interface{}:
+		return io.ReadAll(&ArrayReader{v})
+	}
+	return nil, fmt.Errorf("expected a string or an array of bytes, got %T", v)
+}
+
+// byteArray converts data to the jsonnet representation of bytes, an array of numbers.
+func byteArray(data []byte) []interface{} {
+	res := make([]interface{}, len(data))
+	for i, b := range data {
+		res[i] = float64(b)
+	}
+	return res
 }
 
 func unmarshalYAMLString(yamlStr string) ([]interface{}, error) {
@@ -320,3 +610,26 @@ func dirURL(path string) *url.URL {
 	}
 	return &url.URL{Scheme: "file", Path: path}
 }
+
+// jsonnetValue converts the numbers and byte slices of v to the types native functions can return.
+func jsonnetValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonnetValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonnetValue(e)
+		}
+	case []byte:
+		return string(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return v
+}
@@ -17,12 +17,15 @@ package utils
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
 
 	jsonnet "github.com/google/go-jsonnet"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // check there is no err, and a == b.
@@ -200,6 +203,68 @@ func TestValidateJSONSchema(t *testing.T) {
 	}
 }
 
+func TestHashes(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `std.native("sha256")("foo")`)
+	check(t, err, x, "\"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae\"\n")
+
+	// Strings and arrays of bytes hash alike.
+	x, err = vm.EvaluateSnippet("test", `std.native("sha512")([102, 111, 111]) == std.native("sha512")("foo")`)
+	check(t, err, x, "true\n")
+
+	x, err = vm.EvaluateSnippet("test", `std.native("hmacSha256")("key", "The quick brown fox jumps over the lazy dog")`)
+	check(t, err, x, "\"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8\"\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("sha256")(42)`)
+	if err == nil {
+		t.Errorf("sha256 succeeded with a number")
+	}
+	_, err = vm.EvaluateSnippet("failtest", `std.native("sha256")([256])`)
+	if err == nil {
+		t.Errorf("sha256 succeeded with an invalid byte")
+	}
+}
+
+func TestHex(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `std.native("hexEncode")([0, 15, 255])`)
+	check(t, err, x, "\"000fff\"\n")
+
+	x, err = vm.EvaluateSnippet("test", `std.native("hexDecode")("000fff")`)
+	check(t, err, x, "[\n   0,\n   15,\n   255\n]\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("hexDecode")("0g")`)
+	if err == nil {
+		t.Errorf("hexDecode succeeded with invalid hex")
+	}
+}
+
+func TestBcrypt(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `std.native("bcrypt")("hunter2", 4)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashed string
+	if err := json.Unmarshal([]byte(x), &hashed); err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte("hunter2")); err != nil {
+		t.Errorf("%q is not a hash of the password: %v", hashed, err)
+	}
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("bcrypt")("hunter2", 64)`)
+	if err == nil {
+		t.Errorf("bcrypt succeeded with an invalid cost")
+	}
+}
+
 func TestArrayReader(t *testing.T) {
 	buf := make([]byte, 2)
 	var r io.Reader
@@ -235,3 +300,218 @@ func TestArrayReader(t *testing.T) {
 	r = &ArrayReader{[]interface{}{"bogus"}}
 	assertRead(0, errBadByte, nil)
 }
+
+func TestToml(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `
+    local a = std.native("parseToml")('title = "x"\nport = 8080\nday = 2023-01-02\n[[servers]]\nname = "a"\n[[servers]]\nname = "b"\n');
+    std.manifestJsonMinified(a)`)
+	check(t, err, x, "\"{\\\"day\\\":\\\"2023-01-02\\\",\\\"port\\\":8080,\\\"servers\\\":[{\\\"name\\\":\\\"a\\\"},{\\\"name\\\":\\\"b\\\"}],\\\"title\\\":\\\"x\\\"}\"\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("manifestToml")({b: 1, a: "x", f: 1.5, t: {z: true, y: [1, 2]}})`)
+	check(t, err, x, "\"a = \\\"x\\\"\\nb = 1\\nf = 1.5\\n\\n[t]\\ny = [1, 2]\\nz = true\\n\"\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("manifestToml")({a: null})`)
+	if err == nil {
+		t.Errorf("manifestToml succeeded with a null")
+	}
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("parseToml")("a = ")`)
+	if err == nil {
+		t.Errorf("parseToml succeeded on invalid toml")
+	}
+}
+
+func TestIni(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `
+    local a = std.native("parseIni")("name = x\n[db]\nport = 5432 ; the port\nhost = localhost\n");
+    std.manifestJsonMinified(a)`)
+	check(t, err, x, "\"{\\\"db\\\":{\\\"host\\\":\\\"localhost\\\",\\\"port\\\":\\\"5432\\\"},\\\"name\\\":\\\"x\\\"}\"\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("manifestIni")({z: {b: 2, a: true}, name: "x", db: {host: "h"}})`)
+	check(t, err, x, "\"name = x\\n\\n[db]\\nhost = h\\n\\n[z]\\na = true\\nb = 2\\n\"\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("manifestIni")({s: {a: [1]}})`)
+	if err == nil {
+		t.Errorf("manifestIni succeeded with an array")
+	}
+}
+
+func TestParseHcl(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `
+    local a = std.native("parseHcl")('ui = true\nlistener "tcp" {\n  address = "0.0.0.0:8200"\n  port = 8200\n}\n');
+    [a.ui, a.listener[0].tcp[0].address, a.listener[0].tcp[0].port]`)
+	check(t, err, x, "[\n   true,\n   \"0.0.0.0:8200\",\n   8200\n]\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("parseHcl")("a = {")`)
+	if err == nil {
+		t.Errorf("parseHcl succeeded on invalid hcl")
+	}
+}
+
+func TestParseCsv(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `
+    std.manifestJsonMinified(std.native("parseCsv")('name,port\na,1\n"b, c",2\n', true))`)
+	check(t, err, x, "\"[{\\\"name\\\":\\\"a\\\",\\\"port\\\":\\\"1\\\"},{\\\"name\\\":\\\"b, c\\\",\\\"port\\\":\\\"2\\\"}]\"\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.manifestJsonMinified(std.native("parseCsv")("a,1\nb,2\n", false))`)
+	check(t, err, x, "\"[[\\\"a\\\",\\\"1\\\"],[\\\"b\\\",\\\"2\\\"]]\"\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("parseCsv")("a,b\nc\n", true)`)
+	if err == nil {
+		t.Errorf("parseCsv succeeded with a short record")
+	}
+}
+
+func TestRenderGoTemplate(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	x, err := vm.EvaluateSnippet("test", `
+    std.native("renderGoTemplate")("listen {{ .port }};\nserver_name {{ .name | upper }};", {port: 8080, name: "web"}, {})`)
+	check(t, err, x, "\"listen 8080;\\nserver_name WEB;\"\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("renderGoTemplate")("[[ .a ]] {{ .a }} [[ .missing ]]", {a: "x"}, {delims: ["[[", "]]"]})`)
+	check(t, err, x, "\"x {{ .a }} <no value>\"\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `
+    std.native("renderGoTemplate")("{{ .missing }}", {a: "x"}, {strict: true})`)
+	if err == nil {
+		t.Errorf("renderGoTemplate succeeded with a missing key in strict mode")
+	}
+
+	_, err = vm.EvaluateSnippet("failtest", `
+    std.native("renderGoTemplate")("{{ .a", {a: "x"}, {})`)
+	if err == nil {
+		t.Errorf("renderGoTemplate succeeded on an invalid template")
+	}
+
+	_, err = vm.EvaluateSnippet("failtest", `
+    std.native("renderGoTemplate")("{{ .a }}", {a: "x"}, {unknown: true})`)
+	if err == nil {
+		t.Errorf("renderGoTemplate succeeded with an unknown option")
+	}
+
+	for _, tmpl := range []string{`{{ env "HOME" }}`, `{{ now }}`, `{{ randInt 0 1000000 }}`, `{{ genPrivateKey "rsa" }}`, `{{ "abc" | shuffle }}`} {
+		_, err = vm.EvaluateSnippet("failtest", fmt.Sprintf(`std.native("renderGoTemplate")(%q, {}, {})`, tmpl))
+		if err == nil || !strings.Contains(err.Error(), "not defined") {
+			t.Errorf("renderGoTemplate should not define the function of %s, got: %v", tmpl, err)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	objs := `[
+      {kind: "Service", metadata: {name: "a"}, spec: {ports: [{port: 80}, {port: 443}]}},
+      {kind: "Deployment", metadata: {name: "b"}},
+      {kind: "Service", metadata: {name: "c"}, spec: {ports: [{port: 8080}]}},
+    ]`
+
+	x, err := vm.EvaluateSnippet("test", `
+    std.native("query")(`+objs+`, "[?(@.kind=='Service')].metadata.name", "jsonpath")`)
+	check(t, err, x, "[\n   \"a\",\n   \"c\"\n]\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("query")(`+objs+`, "{[*].spec.ports[*].port}", "jsonpath")`)
+	check(t, err, x, "[\n   80,\n   443,\n   8080\n]\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("query")(`+objs+`, "[*].spec.ports[?(@.port>100)].port", "jsonpath")`)
+	check(t, err, x, "[\n   443,\n   8080\n]\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("query")({items: [{port: 80, ratio: 0.5}, {port: 81, ratio: 1.5}]}, "items[?(@.port==80)].ratio", "jsonpath")`)
+	check(t, err, x, "[\n   0.5\n]\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("query")(`+objs+`, "[0].missing", "jsonpath")`)
+	check(t, err, x, "[ ]\n")
+
+	x, err = vm.EvaluateSnippet("test", `
+    std.native("query")(`+objs+`, ".[] | select(.kind == \"Service\") | .spec.ports | length", "jq")`)
+	check(t, err, x, "[\n   2,\n   1\n]\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("query")({}, "{.a", "jsonpath")`)
+	if err == nil {
+		t.Errorf("query succeeded with an invalid JSONPath")
+	}
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("query")({}, ".a |", "jq")`)
+	if err == nil {
+		t.Errorf("query succeeded with an invalid jq expression")
+	}
+
+	_, err = vm.EvaluateSnippet("failtest", `std.native("query")({}, ".a", "xpath")`)
+	if err == nil {
+		t.Errorf("query succeeded with an unknown language")
+	}
+}
+
+func TestPatches(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	RegisterNativeFuncs(vm, NewIdentityResolver())
+
+	deploy := `{
+      apiVersion: "apps/v1",
+      kind: "Deployment",
+      metadata: {name: "d", labels: {a: "1", b: "2"}},
+      spec: {template: {spec: {containers: [
+        {name: "app", image: "app:v1", args: ["--x", "--y"]},
+        {name: "sidecar", image: "sidecar:v1"},
+      ]}}},
+    }`
+
+	x, err := vm.EvaluateSnippet("test", `
+    local d = std.native("jsonPatch")(`+deploy+`, [
+      {op: "remove", path: "/spec/template/spec/containers/0/args/0"},
+      {op: "replace", path: "/metadata/name", value: "e"},
+    ]);
+    [d.metadata.name, d.spec.template.spec.containers[0].args]`)
+	check(t, err, x, "[\n   \"e\",\n   [\n      \"--y\"\n   ]\n]\n")
+
+	_, err = vm.EvaluateSnippet("failtest", `
+    std.native("jsonPatch")({}, [{op: "remove", path: "/missing"}])`)
+	if err == nil {
+		t.Errorf("jsonPatch succeeded removing a missing field")
+	}
+
+	x, err = vm.EvaluateSnippet("test", `
+    local d = std.native("mergePatch")(`+deploy+`, {metadata: {labels: {a: null, c: "3"}}});
+    d.metadata.labels`)
+	check(t, err, x, "{\n   \"b\": \"2\",\n   \"c\": \"3\"\n}\n")
+
+	// Containers are merged by name.
+	x, err = vm.EvaluateSnippet("test", `
+    local d = std.native("strategicMergePatch")(`+deploy+`, {spec: {template: {spec: {containers: [
+      {name: "sidecar", image: "sidecar:v2"},
+      {name: "app", "$patch": "delete"},
+    ]}}}});
+    [c.name + "=" + c.image for c in d.spec.template.spec.containers]`)
+	check(t, err, x, "[\n   \"sidecar=sidecar:v2\"\n]\n")
+
+	// Custom resources fall back to a merge patch, replacing lists.
+	x, err = vm.EvaluateSnippet("test", `
+    local o = std.native("strategicMergePatch")(
+      {apiVersion: "example.com/v1", kind: "Foo", spec: {items: [{name: "a"}, {name: "b"}]}},
+      {spec: {items: [{name: "c"}]}});
+    o.spec.items`)
+	check(t, err, x, "[\n   {\n      \"name\": \"c\"\n   }\n]\n")
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,10 +28,14 @@ import (
 	"io/fs"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
 	"oras.land/oras-go/pkg/auth/docker"
 )
 
@@ -42,14 +47,37 @@ const (
 )
 
 type OCIBundleConfig struct {
-	Entrypoint string          `json:"entrypoint"`
-	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	Entrypoint string `json:"entrypoint"`
+	// Entrypoints maps the names of additional entrypoints to files of the bundle.
+	// They are selected with oci://repo:tag#name.
+	Entrypoints map[string]string `json:"entrypoints,omitempty"`
+	Metadata    json.RawMessage   `json:"metadata,omitempty"`
+}
+
+// EntrypointPath returns the path of the entrypoint called name, or of the default entrypoint if name is empty.
+func (c OCIBundleConfig) EntrypointPath(name string) (string, error) {
+	if name == "" {
+		// this prevents infinite import recursion
+		if c.Entrypoint == "" {
+			return "", fmt.Errorf(`must use non-empty "entrypoint" config field if you want to render the OCI bundle root`)
+		}
+		return c.Entrypoint, nil
+	}
+	p, found := c.Entrypoints[name]
+	if !found {
+		return "", fmt.Errorf("OCI bundle has no entrypoint %q; available entrypoints: %s", name, strings.Join(sortedKeys(c.Entrypoints), ", "))
+	}
+	return p, nil
 }
 
 type OCIBundle struct {
 	manifest ocispec.Manifest
 	config   OCIBundleConfig
 	files    map[string][]byte
+
+	// only populated by FetchOCIBundle
+	manifestDesc ocispec.Descriptor
+	docs         map[string][]byte
 }
 
 func NewOCIBundle(manifest ocispec.Manifest, config OCIBundleConfig, r io.ReadCloser) (*OCIBundle, error) {
@@ -57,7 +85,71 @@ func NewOCIBundle(manifest ocispec.Manifest, config OCIBundleConfig, r io.ReadCl
 	if err != nil {
 		return nil, err
 	}
-	return &OCIBundle{manifest, config, files}, nil
+	return &OCIBundle{manifest: manifest, config: config, files: files}, nil
+}
+
+// FetchOCIBundle fetches the OCI bundle ref (e.g. "ghcr.io/org/bundle:v1", optionally prefixed by "oci://"),
+// including its documentation layer, going through the disk cache if configured.
+func FetchOCIBundle(ctx context.Context, ref string, opts ...ImporterOption) (*OCIBundle, error) {
+	var importer universalImporter
+	for _, o := range opts {
+		o(&importer)
+	}
+	ref, _, _ = strings.Cut(ref, "#") // entrypoint selector
+	if dir, found := strings.CutPrefix(ref, OCILayoutScheme+"://"); found {
+		o := newOCILayoutImporter()
+		o.trustedKeys = importer.trustedKeys
+		return o.fetchBundle(ctx, dir, true)
+	}
+	o := newOCIImporter()
+	o.diskCache = importer.diskCache
+	o.offline = importer.offline
+	o.policy = importer.policy
+	o.trustedKeys = importer.trustedKeys
+	return o.fetchBundle(ctx, strings.TrimPrefix(ref, "oci://"), true)
+}
+
+// Manifest returns the OCI manifest of the bundle.
+func (o *OCIBundle) Manifest() ocispec.Manifest {
+	return o.manifest
+}
+
+// ManifestDescriptor returns the descriptor of the bundle manifest, including its digest.
+func (o *OCIBundle) ManifestDescriptor() ocispec.Descriptor {
+	return o.manifestDesc
+}
+
+// Config returns the bundle configuration.
+func (o *OCIBundle) Config() OCIBundleConfig {
+	return o.config
+}
+
+// Files returns the sorted paths of the files in the bundle body.
+func (o *OCIBundle) Files() []string {
+	return sortedKeys(o.files)
+}
+
+// DocFiles returns the sorted paths of the files in the documentation layer, if any.
+func (o *OCIBundle) DocFiles() []string {
+	return sortedKeys(o.docs)
+}
+
+// OpenDoc opens a file of the documentation layer.
+func (o *OCIBundle) OpenDoc(path string) (io.ReadCloser, error) {
+	b, found := o.docs[path]
+	if !found {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
 }
 
 func (o *OCIBundle) Open(path string) (io.ReadCloser, error) {
@@ -69,8 +161,15 @@ func (o *OCIBundle) Open(path string) (io.ReadCloser, error) {
 }
 
 type ociImporter struct {
+	// layout is set when reading OCI image layouts instead of registries.
+	layout      bool
 	httpClient  *http.Client
 	bundleCache map[string]*OCIBundle
+	diskCache   *DiskCache
+	offline     bool
+	lock        *Lockfile
+	policy      *ImportPolicy
+	trustedKeys []crypto.PublicKey
 }
 
 func newOCIImporter() *ociImporter {
@@ -86,7 +185,7 @@ func (o *ociImporter) RoundTrip(req *http.Request) (*http.Response, error) {
 	bundle, found := o.bundleCache[pkg]
 	if !found {
 		var err error
-		bundle, err = o.fetchBundle(ctx, pkg)
+		bundle, err = o.fetchBundle(ctx, pkg, false)
 		if err != nil {
 			return nil, err
 		}
@@ -95,12 +194,11 @@ func (o *ociImporter) RoundTrip(req *http.Request) (*http.Response, error) {
 	if path == "" {
 		// cannot just redirect via HTTP here because otherwise relative jsonnet imports
 		// won't be based on the entrypoint file location.
-		imp := fmt.Sprintf("import %q", bundle.config.Entrypoint)
-
-		// this prevents infinite import recursion
-		if bundle.config.Entrypoint == "" {
-			return nil, fmt.Errorf(`must use non-empty "entrypoint" config field if you want to render the OCI bundle root`)
+		entrypoint, err := bundle.config.EntrypointPath(req.URL.Fragment)
+		if err != nil {
+			return nil, err
 		}
+		imp := fmt.Sprintf("import %q", entrypoint)
 		return simpleHTTPResponse(req, http.StatusOK, io.NopCloser(strings.NewReader(imp))), nil
 	}
 
@@ -128,61 +226,243 @@ func simpleHTTPResponse(req *http.Request, statusCode int, r io.ReadCloser) *htt
 	}
 }
 
-func (o *ociImporter) fetchBundle(ctx context.Context, pkg string) (*OCIBundle, error) {
-	cli, err := docker.NewClient()
+// fetchBundle fetches the bundle body and, if withDocs is set, the documentation layer.
+func (o *ociImporter) fetchBundle(ctx context.Context, pkg string, withDocs bool) (*OCIBundle, error) {
+	remote := &ociRemote{importer: o, pkg: pkg}
+
+	manifestDesc, err := o.resolveManifest(ctx, remote)
 	if err != nil {
 		return nil, err
 	}
-	resolver, err := cli.Resolver(ctx, o.httpClient, false)
-	if err != nil {
-		return nil, err
+	scheme := "oci"
+	if o.layout {
+		scheme = OCILayoutScheme
 	}
-
-	fetcher, err := resolver.Fetcher(ctx, pkg)
-	if err != nil {
+	if err := o.lock.CheckImport(scheme+"://"+pkg, manifestDesc.Digest.String()); err != nil {
 		return nil, err
 	}
-
-	_, manifestDesc, err := resolver.Resolve(ctx, pkg)
-	if err != nil {
-		return nil, err
+	if len(o.trustedKeys) > 0 {
+		if err := o.verifySignature(ctx, remote, manifestDesc); err != nil {
+			return nil, err
+		}
 	}
 	var manifest ocispec.Manifest
-	if err := fetchInto(ctx, fetcher, manifestDesc, &manifest); err != nil {
+	if err := o.fetchInto(ctx, remote, manifestDesc, &manifest); err != nil {
 		return nil, err
 	}
 
 	var config OCIBundleConfig
-	if err := fetchInto(ctx, fetcher, manifest.Config, &config); err != nil {
+	if err := o.fetchInto(ctx, remote, manifest.Config, &config); err != nil {
 		return nil, err
 	}
 
+	var bundle *OCIBundle
 	for _, l := range manifest.Layers {
 		if l.MediaType != OCIBundleBodyMediaType {
 			continue
 		}
-		r, err := fetcher.Fetch(ctx, l)
+		b, err := o.fetchBlob(ctx, remote, l)
+		if err != nil {
+			return nil, err
+		}
+		bundle, err = NewOCIBundle(manifest, config, io.NopCloser(bytes.NewReader(b)))
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if bundle == nil {
+		return nil, fmt.Errorf("cannot find layer with mediatype %q", OCIBundleBodyMediaType)
+	}
+	bundle.manifestDesc = manifestDesc
+
+	if withDocs {
+		for _, l := range manifest.Layers {
+			if l.MediaType != OCIBundleDocsMediaType {
+				continue
+			}
+			b, err := o.fetchBlob(ctx, remote, l)
+			if err != nil {
+				return nil, err
+			}
+			if bundle.docs, err = slurpTar(bytes.NewReader(b)); err != nil {
+				return nil, fmt.Errorf("reading docs layer: %w", err)
+			}
+			break
+		}
+	}
+	return bundle, nil
+}
+
+// resolveManifest returns the descriptor of the manifest referenced by the OCI package.
+// References pinned by digest whose manifest is in the disk cache are resolved without network access.
+// When offline, tags are resolved to the digest they pointed to the last time they have been fetched.
+func (o *ociImporter) resolveManifest(ctx context.Context, remote *ociRemote) (ocispec.Descriptor, error) {
+	pkg := remote.pkg
+	if _, dgst, ok := strings.Cut(pkg, "@"); ok && o.diskCache != nil {
+		if _, err := o.diskCache.GetBlob(dgst); err == nil {
+			return ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest(dgst)}, nil
+		}
+	}
+
+	if o.offline {
+		if o.diskCache != nil {
+			entry, err := o.diskCache.GetEntry(CacheKindOCI, pkg)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			if entry != nil {
+				return ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest(entry.Digest)}, nil
+			}
+		}
+		return ocispec.Descriptor{}, fmt.Errorf("resolving %q: %w", pkg, errOffline)
+	}
+
+	if err := remote.init(ctx); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc, err := remote.store.Resolve(ctx, remote.ref)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if o.diskCache != nil {
+		err := o.diskCache.PutEntry(CacheEntry{
+			Kind:    CacheKindOCI,
+			Key:     pkg,
+			Digest:  desc.Digest.String(),
+			Fetched: time.Now(),
+		})
 		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return desc, nil
+}
+
+// fetchBlob returns the content of desc, looking it up in the disk cache first.
+// The content is always checked against the digest of desc, as neither the registry
+// client nor the cache do it, and signatures only cover digests.
+func (o *ociImporter) fetchBlob(ctx context.Context, remote *ociRemote, desc ocispec.Descriptor) ([]byte, error) {
+	if o.diskCache != nil {
+		if b, err := o.diskCache.GetBlob(desc.Digest.String()); err == nil {
+			if verifyBlob(desc, b) == nil {
+				return b, nil
+			}
+			log.Debugf("Ignoring cached blob %s: content doesn't match its digest", desc.Digest)
+		}
+	}
+	if o.offline {
+		return nil, fmt.Errorf("fetching %s from %q: %w", desc.Digest, remote.pkg, errOffline)
+	}
+
+	if err := remote.init(ctx); err != nil {
+		return nil, err
+	}
+	r, err := remote.store.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := o.policy.readAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from %q: %w", desc.Digest, remote.pkg, err)
+	}
+	if err := verifyBlob(desc, b); err != nil {
+		return nil, fmt.Errorf("fetching %s from %q: %w", desc.Digest, remote.pkg, err)
+	}
+
+	// The cache only holds sha256 digests.
+	if o.diskCache != nil && desc.Digest.Algorithm() == digest.SHA256 {
+		if _, err := o.diskCache.putBlob(desc.Digest.String(), b); err != nil {
 			return nil, err
 		}
-		return NewOCIBundle(manifest, config, r)
 	}
-	return nil, fmt.Errorf("cannot find layer with mediatype %q", OCIBundleBodyMediaType)
+	return b, nil
+}
+
+// verifyBlob checks that b is the content of desc.
+func verifyBlob(desc ocispec.Descriptor, b []byte) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return err
+	}
+	if got := desc.Digest.Algorithm().FromBytes(b); got != desc.Digest {
+		return fmt.Errorf("content digest %s doesn't match the expected digest", got)
+	}
+	return nil
 }
 
-func fetchInto(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor, v interface{}) error {
-	c, err := fetcher.Fetch(ctx, desc)
+func (o *ociImporter) fetchInto(ctx context.Context, remote *ociRemote, desc ocispec.Descriptor, v interface{}) error {
+	b, err := o.fetchBlob(ctx, remote, desc)
 	if err != nil {
 		return err
 	}
-	defer c.Close()
-	return json.NewDecoder(c).Decode(v)
+	return json.Unmarshal(b, v)
+}
+
+// ociStore is implemented by registries and OCI image layouts.
+type ociStore interface {
+	Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error)
+	Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error)
+}
+
+// ociRemote lazily connects to the registry hosting an OCI package, or opens the OCI image layout
+// containing it, so that no network access happens when everything can be served from the disk cache.
+type ociRemote struct {
+	importer *ociImporter
+	pkg      string
+
+	store ociStore
+	ref   string // reference of pkg in store
+}
+
+func (r *ociRemote) init(ctx context.Context) error {
+	if r.store != nil {
+		return nil
+	}
+	if r.importer.layout {
+		path, ref := SplitOCILayoutRef(r.pkg)
+		store, err := openOCILayout(ctx, path)
+		if err != nil {
+			return err
+		}
+		r.store, r.ref = store, ref
+		return nil
+	}
+
+	cli, err := docker.NewClient()
+	if err != nil {
+		return err
+	}
+	resolver, err := cli.Resolver(ctx, r.importer.httpClient, false)
+	if err != nil {
+		return err
+	}
+	fetcher, err := resolver.Fetcher(ctx, r.pkg)
+	if err != nil {
+		return err
+	}
+	r.store, r.ref = registryStore{resolver: resolver, Fetcher: fetcher}, r.pkg
+	return nil
+}
+
+type registryStore struct {
+	resolver remotes.Resolver
+	remotes.Fetcher
+}
+
+func (s registryStore) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	_, desc, err := s.resolver.Resolve(ctx, reference)
+	return desc, err
 }
 
+// ociSplitURL splits an OCI URL into the package and the path of a file in the package.
+// The fragment, which selects the entrypoint, is ignored.
 func ociSplitURL(u *url.URL) (string, string) {
 	_, after, _ := strings.Cut(u.Path, ":")
 	_, path, _ := strings.Cut(after, "/")
-	base := strings.TrimPrefix(strings.TrimSuffix(u.String(), "/"+path), "oci://")
+	withoutFragment := *u
+	withoutFragment.Fragment, withoutFragment.RawFragment = "", ""
+	base := strings.TrimPrefix(strings.TrimSuffix(withoutFragment.String(), "/"+path), u.Scheme+"://")
 	return base, path
 }
 
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/tls"
@@ -11,6 +12,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -22,43 +24,47 @@ func TestOCITransport(t *testing.T) {
 		testBody2 = "other dummy string"
 	)
 
+	var body bytes.Buffer
+	gw := gzip.NewWriter(&body)
+	tw := tar.NewWriter(gw)
+	for _, f := range []struct{ name, body string }{{testFile1, testBody1}, {testFile2, testBody2}} {
+		tw.WriteHeader(&tar.Header{
+			Name: f.name,
+			Mode: 0600,
+			Size: int64(len(f.body)),
+		})
+		tw.Write([]byte(f.body))
+	}
+	tw.Close()
+	gw.Close()
+
+	config := `{"entrypoint": "guestbook.jsonnet"}`
+	configDigest := digestOf([]byte(config))
+	bodyDigest := digestOf(body.Bytes())
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.kubecfg.bundle.config.v1+json","digest":%q,"size":%d},"layers":[{"mediaType":"application/vnd.kubecfg.bundle.tar+gzip","digest":%q,"size":%d}],"annotations":{"org.opencontainers.image.created":"2023-01-13T10:16:18Z"}}`,
+		configDigest, len(config), bodyDigest, body.Len())
+	manifestDigest := digestOf([]byte(manifest))
+
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// these mock responses are based on an actual traffic grab of a test artifact I pushed on gcr.io.
+		// the tampered repository serves a config that doesn't match its digest.
+		repo, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/mkm-cloud/"), "/")
 		switch {
-		case r.Method == "HEAD" && r.URL.Path == "/v2/mkm-cloud/hello/manifests/v1":
+		case r.Method == "HEAD" && path == "manifests/v1":
 			w.Header().Add("Content-Type", "application/vnd.oci.image.manifest.v1+json")
 			w.WriteHeader(200)
-		case r.Method == "GET" && r.URL.Path == "/v2/mkm-cloud/hello/manifests/v1":
-			w.Header().Add("Content-Type", "application/vnd.oci.image.manifest.v1+json")
-			fmt.Fprintf(w, "{\"schemaVersion\":2,\"mediaType\":\"application/vnd.oci.image.manifest.v1+json\",\"config\":{\"mediaType\":\"application/vnd.kubecfg.bundle.config.v1+json\",\"digest\":\"sha256:f554a2f13a74f54fa95e1e128ae349bfec6dad4b2d9b836abd90949ef5ea8731\",\"size\":40},\"layers\":[{\"mediaType\":\"application/vnd.kubecfg.bundle.tar+gzip\",\"digest\":\"sha256:cf7d50c45c421eb89cd969d509550abf137220c97e9257fb483c4f3a9548b29f\",\"size\":2036,\"annotations\":{\"io.deis.oras.content.digest\":\"sha256:146e6b19cd18a48247bf8be61821879886f84db9f57eb9f29769db8a9dde816f\",\"io.deis.oras.content.unpack\":\"true\",\"org.opencontainers.image.title\":\".\"}}],\"annotations\":{\"org.opencontainers.image.created\":\"2023-01-13T10:16:18Z\"}}")
-		case r.Method == "GET" && r.URL.Path == "/v2/mkm-cloud/hello/manifests/sha256:db94623ace77be069591d7b435f004ebee6c808fe6f6842df27bb1ce31e2b0ce":
+		case r.Method == "GET" && (path == "manifests/v1" || path == "manifests/"+manifestDigest):
 			w.Header().Add("Content-Type", "application/vnd.oci.image.manifest.v1+json")
-			fmt.Fprintf(w, "{\"schemaVersion\":2,\"mediaType\":\"application/vnd.oci.image.manifest.v1+json\",\"config\":{\"mediaType\":\"application/vnd.kubecfg.bundle.config.v1+json\",\"digest\":\"sha256:f554a2f13a74f54fa95e1e128ae349bfec6dad4b2d9b836abd90949ef5ea8731\",\"size\":40},\"layers\":[{\"mediaType\":\"application/vnd.kubecfg.bundle.tar+gzip\",\"digest\":\"sha256:cf7d50c45c421eb89cd969d509550abf137220c97e9257fb483c4f3a9548b29f\",\"size\":2036,\"annotations\":{\"io.deis.oras.content.digest\":\"sha256:146e6b19cd18a48247bf8be61821879886f84db9f57eb9f29769db8a9dde816f\",\"io.deis.oras.content.unpack\":\"true\",\"org.opencontainers.image.title\":\".\"}}],\"annotations\":{\"org.opencontainers.image.created\":\"2023-01-13T10:16:18Z\"}}")
-		case r.Method == "GET" && r.URL.Path == "/v2/mkm-cloud/hello/blobs/sha256:f554a2f13a74f54fa95e1e128ae349bfec6dad4b2d9b836abd90949ef5ea8731":
+			io.WriteString(w, manifest)
+		case r.Method == "GET" && path == "blobs/"+configDigest:
 			w.Header().Add("Content-Type", OCIBundleConfigMediaType)
-			fmt.Fprintf(w, `{"entrypoint": "guestbook.jsonnet"}`)
-		case r.Method == "GET" && r.URL.Path == "/v2/mkm-cloud/hello/blobs/sha256:cf7d50c45c421eb89cd969d509550abf137220c97e9257fb483c4f3a9548b29f":
-			// the OCI client doesn't really check the hash of the blob, so we can return some random test data.
+			if repo == "tampered" {
+				io.WriteString(w, `{"entrypoint": "other.jsonnet"}`)
+			} else {
+				io.WriteString(w, config)
+			}
+		case r.Method == "GET" && path == "blobs/"+bodyDigest:
 			w.Header().Add("Content-Type", OCIBundleBodyMediaType)
-			gw := gzip.NewWriter(w)
-			defer gw.Close()
-			tw := tar.NewWriter(gw)
-
-			tw.WriteHeader(&tar.Header{
-				Name: testFile1,
-				Mode: 0600,
-				Size: int64(len(testBody1)),
-			})
-			tw.Write([]byte(testBody1))
-
-			tw.WriteHeader(&tar.Header{
-				Name: testFile2,
-				Mode: 0600,
-				Size: int64(len(testBody2)),
-			})
-			tw.Write([]byte(testBody2))
-
-			tw.Close()
+			w.Write(body.Bytes())
 		default:
 			http.Error(w, fmt.Sprintf("unhandled request %v", r), 500)
 		}
@@ -114,6 +120,10 @@ func TestOCITransport(t *testing.T) {
 			}
 		})
 	}
+
+	if _, err := cl.Get("oci://gcr.io/mkm-cloud/tampered:v1"); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("expected digest mismatch error, got: %v", err)
+	}
 }
 
 func TestOCISplitURL(t *testing.T) {
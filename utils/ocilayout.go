@@ -0,0 +1,70 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// OCILayoutScheme is the URL scheme of imports from OCI image layouts, e.g.
+// oci-layout:///path/to/layout:v1 or oci-layout:///path/to/layout.tar:v1/lib/file.libsonnet.
+const OCILayoutScheme = "oci-layout"
+
+// newOCILayoutImporter returns an importer of bundles stored in OCI image layouts.
+// Layouts are local, so they are neither cached nor affected by --offline.
+func newOCILayoutImporter() *ociImporter {
+	o := newOCIImporter()
+	o.layout = true
+	return o
+}
+
+// SplitOCILayoutRef splits a reference to a manifest in an OCI image layout, like "path/to/layout:tag"
+// or "path/to/layout@sha256:...", into the layout path and the tag or digest. The tag defaults to "latest".
+func SplitOCILayoutRef(s string) (path, reference string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		return s[:i], s[i+1:]
+	}
+	return s, "latest"
+}
+
+// IsOCILayoutTarball returns true if the layout at path is stored in a tar archive rather than in a directory.
+func IsOCILayoutTarball(path string) bool {
+	return strings.HasSuffix(path, ".tar")
+}
+
+func openOCILayout(ctx context.Context, path string) (ociStore, error) {
+	var (
+		store ociStore
+		err   error
+	)
+	if IsOCILayoutTarball(path) {
+		store, err = oci.NewFromTar(ctx, path)
+	} else {
+		store, err = oci.NewFromFS(ctx, os.DirFS(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening OCI image layout %q: %w", path, err)
+	}
+	return store, nil
+}
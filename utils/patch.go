@@ -0,0 +1,113 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// applyJSONPatch applies the RFC 6902 JSON Patch ops to doc.
+func applyJSONPatch(doc, ops interface{}) (interface{}, error) {
+	docData, opsData, err := marshalPair(doc, ops)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.DecodePatch(opsData)
+	if err != nil {
+		return nil, err
+	}
+	res, err := patch.Apply(docData)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPatched(res)
+}
+
+// applyMergePatch applies the RFC 7386 JSON merge patch to doc.
+func applyMergePatch(doc, patch interface{}) (interface{}, error) {
+	docData, patchData, err := marshalPair(doc, patch)
+	if err != nil {
+		return nil, err
+	}
+	res, err := jsonpatch.MergePatch(docData, patchData)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPatched(res)
+}
+
+// applyStrategicMergePatch applies the strategic merge patch to obj, with the patch
+// strategies and merge keys of the built-in Kubernetes type of obj. The kinds unknown
+// to client-go, like custom resources, have no such metadata, and are patched with a
+// JSON merge patch, like kubectl does.
+func applyStrategicMergePatch(obj, patch interface{}) (interface{}, error) {
+	original, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", obj)
+	}
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected the patch to be an object, got %T", patch)
+	}
+	apiVersion, _ := original["apiVersion"].(string)
+	kind, _ := original["kind"].(string)
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	gvk := gv.WithKind(kind)
+
+	dataStruct, err := scheme.Scheme.New(gvk)
+	if runtime.IsNotRegisteredError(err) {
+		log.Debugf("No built-in type for %s, using a JSON merge patch", gvk)
+		return applyMergePatch(obj, patch)
+	} else if err != nil {
+		return nil, err
+	}
+	res, err := strategicpatch.StrategicMergeMapPatch(original, patchMap, dataStruct)
+	if err != nil {
+		return nil, err
+	}
+	return jsonnetValue(map[string]interface{}(res)), nil
+}
+
+func marshalPair(a, b interface{}) ([]byte, []byte, error) {
+	aData, err := json.Marshal(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	bData, err := json.Marshal(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aData, bData, nil
+}
+
+func unmarshalPatched(data []byte) (interface{}, error) {
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
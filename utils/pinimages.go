@@ -0,0 +1,180 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+)
+
+// podSpecContainerFields are the fields of a PodSpec holding containers.
+var podSpecContainerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// ImagePinner rewrites the container images of rendered objects to image@digest.
+// Digests are pinned in a lockfile, so that repeated runs render the same images
+// and don't need to access the registries.
+type ImagePinner struct {
+	resolver Resolver
+	lockfile *Lockfile
+	disco    discovery.OpenAPISchemaInterface
+	offline  bool
+	platform *v1.Platform
+}
+
+// NewImagePinner returns an ImagePinner that resolves the digests of the images not pinned in lockfile
+// with resolver, unless offline is set. lockfile may be nil.
+// Built-in workload kinds are always handled; the PodSpecs of other kinds are found from the
+// schema served by disco, if not nil.
+// If platform is not nil, multi-platform images are pinned to the manifest of that platform, which
+// resolver must resolve to. A nil resolver looks up the registries of the images.
+//
+// The images are renamed like resolver renames them, e.g. to a mirror, including the ones pinned in lockfile.
+func NewImagePinner(resolver Resolver, lockfile *Lockfile, disco discovery.OpenAPISchemaInterface, offline bool, platform *v1.Platform) *ImagePinner {
+	if resolver == nil {
+		resolver = NewRegistryResolver(WithPlatform(platform))
+	}
+	return &ImagePinner{
+		resolver: resolver,
+		lockfile: lockfile,
+		disco:    disco,
+		offline:  offline,
+		platform: platform,
+	}
+}
+
+// PinObjects rewrites in place the images of all the containers of the pod specs found in objs.
+func (p *ImagePinner) PinObjects(objs []*unstructured.Unstructured) error {
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		paths, err := podSpecPaths(p.disco, gvk)
+		if err != nil {
+			log.Warnf("Not pinning images of %s %s: cannot find its schema: %v", gvk.Kind, obj.GetName(), err)
+			continue
+		}
+		for _, path := range paths {
+			err := visitPath(obj.Object, path, func(v interface{}) error {
+				spec, ok := v.(map[string]interface{})
+				if !ok {
+					return nil
+				}
+				return p.pinPodSpec(spec)
+			})
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ImagePinner) pinPodSpec(spec map[string]interface{}) error {
+	for _, field := range podSpecContainerFields {
+		containers, _ := spec[field].([]interface{})
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			image, ok := container["image"].(string)
+			if !ok || image == "" {
+				continue
+			}
+			pinned, err := p.Pin(image)
+			if err != nil {
+				return err
+			}
+			container["image"] = pinned
+		}
+	}
+	return nil
+}
+
+// Pin returns image@digest. Images that already have a digest are returned as they are.
+func (p *ImagePinner) Pin(image string) (string, error) {
+	n, err := ParseImageName(image)
+	if err != nil {
+		return "", err
+	}
+	if n.Digest != "" {
+		return image, nil
+	}
+	key := n.String()
+	if p.platform != nil {
+		// the same image has different digests on different platforms
+		key += "#" + p.platform.String()
+	}
+	digest, err := p.lockfile.PinImage(key, func() (string, error) {
+		if p.offline {
+			return "", fmt.Errorf("image %q is not pinned in the lockfile and cannot be resolved offline", image)
+		}
+		resolved := n
+		if err := p.resolver.Resolve(&resolved); err != nil {
+			return "", err
+		}
+		return resolved.Digest, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Resolvers don't look up images that have a digest, but still rename them.
+	renamed := n
+	renamed.Digest = digest
+	if err := p.resolver.Resolve(&renamed); err != nil {
+		return "", err
+	}
+	if renamed.Registry == n.Registry && renamed.Repository == n.Repository {
+		return image + "@" + digest, nil
+	}
+	return fmt.Sprintf("%s/%s:%s@%s", renamed.Registry, renamed.Repository, n.Tag, renamed.Digest), nil
+}
+
+// visitPath calls fn with the values at path in obj. The "*" step matches every
+// element of an array or map.
+func visitPath(obj interface{}, path []string, fn func(interface{}) error) error {
+	if len(path) == 0 {
+		return fn(obj)
+	}
+	step, rest := path[0], path[1:]
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if step != "*" {
+			if v, found := o[step]; found {
+				return visitPath(v, rest, fn)
+			}
+			return nil
+		}
+		for _, k := range sortedKeys(o) {
+			if err := visitPath(o[k], rest, fn); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if step != "*" {
+			return nil
+		}
+		for _, v := range o {
+			if err := visitPath(v, rest, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
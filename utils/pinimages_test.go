@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPodSpecVisitor(t *testing.T) {
+	disco := schemaFromFile{dir: filepath.FromSlash("../testdata")}
+	for _, gvk := range []schema.GroupVersionKind{
+		{Version: "v1", Kind: "Pod"},
+		{Version: "v1", Kind: "ReplicationController"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "batch", Version: "v1beta1", Kind: "CronJob"},
+	} {
+		oapi, err := NewOpenAPISchemaFor(disco, gvk)
+		if err != nil {
+			t.Fatalf("%s: %v", gvk, err)
+		}
+		var v podSpecVisitor
+		oapi.schema.Accept(&v)
+		if got, want := v.paths, wellKnownPodSpecPaths[gvk.GroupKind()]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", gvk, got, want)
+		}
+	}
+}
+
+func TestImagePinner(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	image := strings.TrimPrefix(srv.URL, "http://") + "/app:v1"
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newObjs := func() []*unstructured.Unstructured {
+		container := func(image string) interface{} { return map[string]interface{}{"name": "c", "image": image} }
+		return []*unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "CronJob",
+				"spec": map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+					"initContainers": []interface{}{container(image)},
+					"containers":     []interface{}{container(image), container("busybox@sha256:" + strings.Repeat("0", 64))},
+				}}}}},
+			}},
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"data":       map[string]interface{}{"image": image},
+			}},
+		}
+	}
+	images := func(objs []*unstructured.Unstructured) []interface{} {
+		var res []interface{}
+		spec := objs[0].Object["spec"].(map[string]interface{})["jobTemplate"].(map[string]interface{})["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+		for _, f := range []string{"initContainers", "containers"} {
+			for _, c := range spec[f].([]interface{}) {
+				res = append(res, c.(map[string]interface{})["image"])
+			}
+		}
+		return append(res, objs[1].Object["data"].(map[string]interface{})["image"])
+	}
+	want := []interface{}{image + "@" + dgst.String(), image + "@" + dgst.String(), "busybox@sha256:" + strings.Repeat("0", 64), image}
+
+	path := filepath.Join(t.TempDir(), DefaultLockfileName)
+	lockfile, err := LoadLockfile(path, LockUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := newObjs()
+	if err := NewImagePinner(nil, lockfile, nil, false, nil).PinObjects(objs); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := images(objs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The pinned digest is used offline, even if the tag moved.
+	srv.Close()
+	lockfile, err = LoadLockfile(path, LockEnforce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs = newObjs()
+	if err := NewImagePinner(nil, lockfile, nil, true, nil).PinObjects(objs); err != nil {
+		t.Fatal(err)
+	}
+	if got := images(objs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := NewImagePinner(nil, lockfile, nil, true, nil).Pin("example.com/other:v1"); err == nil || !strings.Contains(err.Error(), "not pinned") {
+		t.Errorf("expected not pinned error, got: %v", err)
+	}
+}
+
+func TestImagePinnerMirror(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	mirror := strings.TrimPrefix(srv.URL, "http://") + "/dockerhub/"
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(mirror + "library/nginx:1.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := NewMirrorResolver([]ImageRewriteRule{{Prefix: "docker.io/", Replacement: mirror}}, NewRegistryResolver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), DefaultLockfileName)
+	lockfile, err := LoadLockfile(path, LockUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mirror + "library/nginx:1.25@" + dgst.String()
+	if got, err := NewImagePinner(resolver, lockfile, nil, false, nil).Pin("nginx:1.25"); err != nil || got != want {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+	if err := lockfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Images pinned in the lockfile are renamed too.
+	srv.Close()
+	lockfile, err = LoadLockfile(path, LockEnforce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewImagePinner(resolver, lockfile, nil, true, nil).Pin("nginx:1.25"); err != nil || got != want {
+		t.Errorf("offline: got %q, %v, want %q", got, err, want)
+	}
+}
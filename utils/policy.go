@@ -0,0 +1,128 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ImportPolicy restricts what jsonnet code is allowed to import.
+// Empty lists don't restrict anything.
+type ImportPolicy struct {
+	// AllowedRoots are the directories local files can be imported from (file:// and git+file:// URLs).
+	// Symlinks are resolved before checking, so they cannot be used to escape the roots.
+	AllowedRoots []string
+	// AllowedHosts are the hosts remote imports can be fetched from. A leading "*." matches any subdomain.
+	AllowedHosts []string
+	// AllowedSchemes are the URL schemes that can be imported (e.g. "file", "https", "oci").
+	// Embedded libraries (internal://) are always allowed.
+	AllowedSchemes []string
+	// MaxDownloadSize is the maximum size in bytes of any single imported file. Zero means unlimited.
+	MaxDownloadSize int64
+}
+
+// WithImportPolicy makes the importer reject the imports not allowed by the policy.
+func WithImportPolicy(p *ImportPolicy) ImporterOption {
+	return func(importer *universalImporter) {
+		importer.policy = p
+	}
+}
+
+// Check returns an error if importing u is not allowed.
+func (p *ImportPolicy) Check(u *url.URL) error {
+	if p == nil || u.Scheme == "internal" {
+		return nil
+	}
+
+	if len(p.AllowedSchemes) > 0 && !slices.Contains(p.AllowedSchemes, u.Scheme) {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return p.checkPath(u.Path)
+	case "git+file":
+		repo, _, _ := strings.Cut(u.Path, "//")
+		return p.checkPath(repo)
+	case OCILayoutScheme:
+		pkg, _ := ociSplitURL(u)
+		layout, _ := SplitOCILayoutRef(pkg)
+		return p.checkPath(layout)
+	}
+
+	if len(p.AllowedHosts) > 0 {
+		host := u.Hostname()
+		for _, allowed := range p.AllowedHosts {
+			if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host %q is not allowed", host)
+	}
+	return nil
+}
+
+func (p *ImportPolicy) checkPath(path string) error {
+	if len(p.AllowedRoots) == 0 {
+		return nil
+	}
+	path = realPath(filepath.FromSlash(path))
+	for _, root := range p.AllowedRoots {
+		rel, err := filepath.Rel(realPath(root), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %q is outside of the allowed roots", path)
+}
+
+// realPath returns the absolute path with all symlinks resolved. When the path doesn't exist (yet)
+// the symlinks in its longest existing prefix are resolved.
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	var rest []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		if filepath.Dir(dir) == dir {
+			return filepath.Clean(path)
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// readAll reads r, failing if it's larger than the maximum download size.
+func (p *ImportPolicy) readAll(r io.Reader) ([]byte, error) {
+	if p == nil || p.MaxDownloadSize <= 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, p.MaxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > p.MaxDownloadSize {
+		return nil, fmt.Errorf("import is larger than the maximum download size of %d bytes", p.MaxDownloadSize)
+	}
+	return b, nil
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportPolicyCheck(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &ImportPolicy{
+		AllowedRoots:   []string{root},
+		AllowedHosts:   []string{"example.com", "*.example.org"},
+		AllowedSchemes: []string{"file", "https", "git+file", "oci-layout"},
+	}
+
+	testCases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"file://" + root + "/lib.libsonnet", true},
+		{"file://" + root + "/../lib.libsonnet", false},
+		{"file://" + root + "/escape/lib.libsonnet", false},
+		{"file://" + outside + "/lib.libsonnet", false},
+		{"git+file://" + root + "/repo.git//lib.libsonnet", true},
+		{"git+file://" + outside + "/repo.git//lib.libsonnet", false},
+		{"oci-layout://" + root + "/layout.tar:v1/lib.libsonnet", true},
+		{"oci-layout://" + outside + "/layout:v1", false},
+		{"https://example.com/lib.libsonnet", true},
+		{"https://sub.example.org/lib.libsonnet", true},
+		{"https://example.org.evil.com/lib.libsonnet", false},
+		{"https://evil.com/lib.libsonnet", false},
+		{"http://example.com/lib.libsonnet", false},
+		{"internal:///kubecfg.libsonnet", true},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Check(u); (err == nil) != tc.allowed {
+			t.Errorf("%s: allowed=%v, got error: %v", tc.url, tc.allowed, err)
+		}
+	}
+
+	var nilPolicy *ImportPolicy
+	if err := nilPolicy.Check(&url.URL{Scheme: "https", Host: "evil.com"}); err != nil {
+		t.Errorf("nil policy should allow everything, got: %v", err)
+	}
+}
+
+func TestImporterPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big.libsonnet":
+			w.Write([]byte(strings.Repeat(" ", 100) + "{}"))
+		case "/redirect.libsonnet":
+			http.Redirect(w, r, "https://evil.com/lib.libsonnet", http.StatusFound)
+		default:
+			w.Write([]byte("{}"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	srvURL, _ := url.Parse(srv.URL)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.libsonnet"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	importer := MakeUniversalImporter(nil, false, WithImportPolicy(&ImportPolicy{
+		AllowedRoots:    []string{t.TempDir()},
+		AllowedHosts:    []string{srvURL.Hostname()},
+		MaxDownloadSize: 10,
+	}))
+
+	if _, _, err := importer.Import("", srv.URL+"/small.libsonnet"); err != nil {
+		t.Errorf("allowed import failed: %v", err)
+	}
+
+	testCases := []struct {
+		url  string
+		want string
+	}{
+		{"file://" + dir + "/secret.libsonnet", "denied by import policy"},
+		{srv.URL + "/big.libsonnet", "maximum download size"},
+		{srv.URL + "/redirect.libsonnet", "denied by import policy"},
+	}
+	for _, tc := range testCases {
+		_, _, err := importer.Import("", tc.url)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got: %v", tc.url, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/itchyny/gojq"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// The languages of query.
+const (
+	QueryJSONPath = "jsonpath"
+	QueryJq       = "jq"
+)
+
+// query returns the values of obj matching expr, in the given language.
+func query(obj interface{}, expr, language string) ([]interface{}, error) {
+	switch language {
+	case QueryJSONPath:
+		return queryJSONPath(obj, expr)
+	case QueryJq:
+		return queryJq(obj, expr)
+	}
+	return nil, fmt.Errorf("unknown query language %q, expected %q or %q", language, QueryJSONPath, QueryJq)
+}
+
+// queryJSONPath runs a kubectl JSONPath expression. Like kubectl, the braces around the
+// expression and its leading dot may be omitted, e.g. "items[*].metadata.name".
+func queryJSONPath(obj interface{}, expr string) ([]interface{}, error) {
+	if !strings.Contains(expr, "{") {
+		if !strings.HasPrefix(expr, ".") && !strings.HasPrefix(expr, "[") && !strings.HasPrefix(expr, "$") {
+			expr = "." + expr
+		}
+		expr = "{" + expr + "}"
+	}
+	jp := jsonpath.New("query").AllowMissingKeys(true)
+	if err := jp.Parse(expr); err != nil {
+		return nil, err
+	}
+	results, err := jp.FindResults(jsonPathValue(obj))
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for _, values := range results {
+		for _, v := range values {
+			res = append(res, jsonnetValue(v.Interface()))
+		}
+	}
+	return res, nil
+}
+
+// jsonPathValue returns a copy of v where integral numbers are int64, as JSONPath
+// compares the numbers of filters like [?(@.port==80)] only to values of the same kind.
+func jsonPathValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			res[k] = jsonPathValue(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = jsonPathValue(e)
+		}
+		return res
+	}
+	return v
+}
+
+// queryJq runs a jq expression, and returns all the values it emits.
+func queryJq(obj interface{}, expr string) ([]interface{}, error) {
+	q, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	iter := q.Run(obj)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		res = append(res, jqToJsonnet(v))
+	}
+	return res, nil
+}
+
+func jqToJsonnet(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jqToJsonnet(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jqToJsonnet(e)
+		}
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	}
+	return jsonnetValue(v)
+}
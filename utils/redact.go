@@ -0,0 +1,239 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// RedactedValue replaces sensitive values.
+	RedactedValue = "(redacted)"
+	// RedactedChangedValue replaces sensitive values that differ from the other side of a diff.
+	RedactedChangedValue = "(redacted, changed)"
+
+	// minRedactedLength is the length under which values are not scrubbed from text, as
+	// they would match too many unrelated words.
+	minRedactedLength = 4
+)
+
+// SensitivePath is the path of sensitive fields in objects, like "data.*". Path elements are
+// separated by dots, which are escaped as "\." in keys, and "*" matches any key or array index.
+// All the values under the path are sensitive. If Kind is set, the path only applies to objects
+// of that kind.
+type SensitivePath struct {
+	Kind string `json:"kind,omitempty" mapstructure:"kind"`
+	Path string `json:"path" mapstructure:"path"`
+}
+
+// DefaultSensitivePaths are always redacted. They include the last applied configuration of
+// Secrets, which holds their data too.
+var DefaultSensitivePaths = []SensitivePath{
+	{Kind: "Secret", Path: "data"},
+	{Kind: "Secret", Path: "stringData"},
+	{Kind: "Secret", Path: `metadata.annotations.kubectl\.kubernetes\.io/last-applied-configuration`},
+	{Kind: "Secret", Path: `metadata.annotations.kubecfg\.ksonnet\.io/last-applied-configuration`},
+}
+
+// Redactor hides the sensitive values of objects, e.g. before printing them. It also remembers
+// the values it has seen, so that they can be scrubbed from free text like logs and errors.
+// It is safe for concurrent use.
+type Redactor struct {
+	paths []sensitivePath
+
+	mu       sync.Mutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+type sensitivePath struct {
+	kind string
+	path []string
+}
+
+// NewRedactor returns a Redactor of the DefaultSensitivePaths and of paths.
+func NewRedactor(paths []SensitivePath) *Redactor {
+	r := &Redactor{values: map[string]bool{}}
+	for _, p := range append(DefaultSensitivePaths[:len(DefaultSensitivePaths):len(DefaultSensitivePaths)], paths...) {
+		r.paths = append(r.paths, sensitivePath{kind: p.Kind, path: splitSensitivePath(p.Path)})
+	}
+	return r
+}
+
+// Observe remembers the sensitive values of objs, without changing them.
+func (r *Redactor) Observe(objs ...*unstructured.Unstructured) {
+	for _, obj := range objs {
+		if obj != nil {
+			r.visit(obj.Object, func(_ string, v interface{}) interface{} { return v })
+		}
+	}
+}
+
+// Redact returns a copy of obj with its sensitive values replaced by RedactedValue.
+func (r *Redactor) Redact(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	_, res := r.RedactPair(nil, obj)
+	return res
+}
+
+// RedactPair returns copies of two versions of an object with their sensitive values redacted.
+// Values of newObj that differ from the ones of oldObj are replaced by RedactedChangedValue,
+// so that a diff of the copies still shows which values change. Either object can be nil.
+func (r *Redactor) RedactPair(oldObj, newObj *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	oldValues := map[string]interface{}{}
+	if oldObj != nil {
+		oldObj = oldObj.DeepCopy()
+		r.visit(oldObj.Object, func(path string, v interface{}) interface{} {
+			oldValues[path] = v
+			return RedactedValue
+		})
+	}
+	if newObj != nil {
+		newObj = newObj.DeepCopy()
+		r.visit(newObj.Object, func(path string, v interface{}) interface{} {
+			if old, ok := oldValues[path]; ok && !reflect.DeepEqual(old, v) {
+				return RedactedChangedValue
+			}
+			return RedactedValue
+		})
+	}
+	return oldObj, newObj
+}
+
+// RedactString replaces the sensitive values seen so far in s by RedactedValue.
+func (r *Redactor) RedactString(s string) string {
+	r.mu.Lock()
+	if r.replacer == nil {
+		values := make([]string, 0, len(r.values))
+		for v := range r.values {
+			values = append(values, v)
+		}
+		// Longer values first, as they may contain shorter ones.
+		sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+		oldnew := make([]string, 0, 2*len(values))
+		for _, v := range values {
+			oldnew = append(oldnew, v, RedactedValue)
+		}
+		r.replacer = strings.NewReplacer(oldnew...)
+	}
+	replacer := r.replacer
+	r.mu.Unlock()
+	return replacer.Replace(s)
+}
+
+// visit replaces the sensitive values of obj, which are scalars, with the result of fn.
+// Values are remembered before being passed to fn.
+func (r *Redactor) visit(obj map[string]interface{}, fn func(path string, v interface{}) interface{}) {
+	kind, _ := obj["kind"].(string)
+	seen := map[string]bool{}
+	var walk func(v interface{}, pattern, path []string) interface{}
+	walk = func(v interface{}, pattern, path []string) interface{} {
+		key := strings.Join(path, ".")
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				if len(pattern) == 0 || pattern[0] == "*" || pattern[0] == k {
+					v[k] = walk(e, tail(pattern), append(path[:len(path):len(path)], k))
+				}
+			}
+			return v
+		case []interface{}:
+			for i, e := range v {
+				idx := strconv.Itoa(i)
+				if len(pattern) == 0 || pattern[0] == "*" || pattern[0] == idx {
+					v[i] = walk(e, tail(pattern), append(path[:len(path):len(path)], idx))
+				}
+			}
+			return v
+		case nil:
+			return nil
+		}
+		if len(pattern) > 0 || seen[key] {
+			return v
+		}
+		seen[key] = true
+		r.remember(v)
+		return fn(key, v)
+	}
+	for _, p := range r.paths {
+		if p.kind == "" || p.kind == kind {
+			walk(obj, p.path, nil)
+		}
+	}
+}
+
+// splitSensitivePath splits path at the dots that are not escaped.
+func splitSensitivePath(path string) []string {
+	var (
+		res  []string
+		elem strings.Builder
+	)
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			elem.WriteByte('.')
+			i++
+		case path[i] == '.':
+			res = append(res, elem.String())
+			elem.Reset()
+		default:
+			elem.WriteByte(path[i])
+		}
+	}
+	return append(res, elem.String())
+}
+
+func tail(pattern []string) []string {
+	if len(pattern) == 0 {
+		return nil
+	}
+	return pattern[1:]
+}
+
+// remember adds the forms in which a sensitive value may appear in text to the values to scrub.
+func (r *Redactor) remember(v interface{}) {
+	s, ok := v.(string)
+	if !ok {
+		return
+	}
+	forms := []string{s}
+	if b, err := json.Marshal(s); err == nil {
+		forms = append(forms, string(b[1:len(b)-1]))
+	}
+	// Secret data is base64 encoded, stringData is not.
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && utf8.Valid(b) {
+		forms = append(forms, string(b))
+	} else {
+		forms = append(forms, base64.StdEncoding.EncodeToString([]byte(s)))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range forms {
+		if len(f) >= minRedactedLength && !r.values[f] {
+			r.values[f] = true
+			r.replacer = nil
+		}
+	}
+}
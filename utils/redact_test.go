@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor([]SensitivePath{
+		{Kind: "ConfigMap", Path: "data.password"},
+		{Path: "spec.containers.*.env.*.value"},
+		{Path: `metadata.annotations.example\.com/token`},
+	})
+
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "foo"},
+		"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+		"stringData": map[string]interface{}{"key": "-----BEGIN KEY-----\nabcd\n-----END KEY-----\n"},
+	}}
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":        "foo",
+			"annotations": map[string]interface{}{"example.com/token": "s3cr3t", "other": "visible"},
+		},
+		"data": map[string]interface{}{"password": "visible"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name": "app",
+					"env":  []interface{}{map[string]interface{}{"name": "TOKEN", "value": "t0k3n"}},
+				},
+			},
+		},
+	}}
+
+	got := r.Redact(secret)
+	want := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "foo"},
+		"data":       map[string]interface{}{"password": RedactedValue},
+		"stringData": map[string]interface{}{"key": RedactedValue},
+	}
+	if !reflect.DeepEqual(got.Object, want) {
+		t.Errorf("got: %v, want: %v", got.Object, want)
+	}
+	if secret.Object["data"].(map[string]interface{})["password"] != "aHVudGVyMg==" {
+		t.Errorf("Redact changed its input")
+	}
+
+	got = r.Redact(pod)
+	annotations, _, _ := unstructured.NestedStringMap(got.Object, "metadata", "annotations")
+	if want := map[string]string{"example.com/token": RedactedValue, "other": "visible"}; !reflect.DeepEqual(annotations, want) {
+		t.Errorf("annotations: got %v, want %v", annotations, want)
+	}
+	if v, _, _ := unstructured.NestedString(got.Object, "data", "password"); v != "visible" {
+		t.Errorf("data of a Pod: got %q, want it unchanged", v)
+	}
+	containers, _, _ := unstructured.NestedSlice(got.Object, "spec", "containers")
+	env, _, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+	if want := map[string]interface{}{"name": "TOKEN", "value": RedactedValue}; !reflect.DeepEqual(env[0], want) {
+		t.Errorf("env: got %v, want %v", env[0], want)
+	}
+
+	for input, want := range map[string]string{
+		`cannot set password hunter2`:                              `cannot set password (redacted)`,
+		`data: {"password": "aHVudGVyMg=="}`:                       `data: {"password": "(redacted)"}`,
+		`{"key":"-----BEGIN KEY-----\nabcd\n-----END KEY-----\n"}`: `{"key":"(redacted)"}`,
+		`token=t0k3n app=foo`:                                      `token=(redacted) app=foo`,
+	} {
+		if got := r.RedactString(input); got != want {
+			t.Errorf("RedactString(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRedactPair(t *testing.T) {
+	secret := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Secret", "data": data}}
+	}
+	live, config := NewRedactor(nil).RedactPair(
+		secret(map[string]interface{}{"same": "YQ==", "changed": "Yg==", "removed": "Yw=="}),
+		secret(map[string]interface{}{"same": "YQ==", "changed": "ZA==", "added": "ZQ=="}),
+	)
+	if want := map[string]interface{}{"same": RedactedValue, "changed": RedactedValue, "removed": RedactedValue}; !reflect.DeepEqual(live.Object["data"], want) {
+		t.Errorf("live: got %v, want %v", live.Object["data"], want)
+	}
+	if want := map[string]interface{}{"same": RedactedValue, "changed": RedactedChangedValue, "added": RedactedValue}; !reflect.DeepEqual(config.Object["data"], want) {
+		t.Errorf("config: got %v, want %v", config.Object["data"], want)
+	}
+}
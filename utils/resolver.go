@@ -22,7 +22,9 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	log "github.com/sirupsen/logrus"
 )
 
 // ImageName represents the parts of a docker image name
@@ -87,6 +89,12 @@ type Resolver interface {
 	Resolve(image *ImageName) error
 }
 
+// ImageParser is implemented by resolvers that accept image names ParseImageName rejects,
+// like the semver constraints of the semver resolver.
+type ImageParser interface {
+	ParseImage(image string) (ImageName, error)
+}
+
 // NewIdentityResolver returns a resolver that does only trivial
 // :latest canonicalisation
 func NewIdentityResolver() Resolver {
@@ -99,16 +107,32 @@ func (r identityResolver) Resolve(image *ImageName) error {
 	return nil
 }
 
+// RegistryResolverOption configures the resolver returned by NewRegistryResolver.
+type RegistryResolverOption func(*registryResolver)
+
+// WithPlatform resolves multi-platform images to the digest of the manifest of platform,
+// rather than to the digest of their index. A nil platform keeps the index digest.
+func WithPlatform(platform *v1.Platform) RegistryResolverOption {
+	return func(r *registryResolver) {
+		r.platform = platform
+	}
+}
+
 // NewRegistryResolver returns a resolver that looks up a docker
 // registry to resolve digests
-func NewRegistryResolver() Resolver {
-	return &registryResolver{
+func NewRegistryResolver(opts ...RegistryResolverOption) Resolver {
+	r := &registryResolver{
 		cache: make(map[string]string),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 type registryResolver struct {
-	cache map[string]string
+	cache    map[string]string
+	platform *v1.Platform
 }
 
 func (r *registryResolver) Resolve(n *ImageName) error {
@@ -139,8 +163,50 @@ func (r *registryResolver) Resolve(n *ImageName) error {
 		return fmt.Errorf("fetching manifest of %q: %w", image, err)
 	}
 
-	n.Digest = dsc.Digest.String()
+	digest := dsc.Digest
+	if r.platform != nil {
+		if digest, err = r.platformDigest(image, dsc); err != nil {
+			return err
+		}
+	}
+
+	n.Digest = digest.String()
 	r.cache[image] = n.Digest
 
 	return nil
 }
+
+// platformDigest returns the digest of the manifest of r.platform in the index dsc.
+// Images without such a manifest resolve to the digest of dsc, with a warning.
+func (r *registryResolver) platformDigest(image string, dsc *remote.Descriptor) (v1.Hash, error) {
+	if !dsc.MediaType.IsIndex() {
+		img, err := dsc.Image()
+		if err != nil {
+			return v1.Hash{}, fmt.Errorf("fetching image %q: %w", image, err)
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return v1.Hash{}, fmt.Errorf("fetching config of %q: %w", image, err)
+		}
+		if p := cfg.Platform(); p == nil || !p.Satisfies(*r.platform) {
+			log.Warnf("Image %q is not built for platform %s", image, r.platform)
+		}
+		return dsc.Digest, nil
+	}
+
+	idx, err := dsc.ImageIndex()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("fetching index of %q: %w", image, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("fetching index of %q: %w", image, err)
+	}
+	for _, m := range manifest.Manifests {
+		if m.Platform != nil && m.Platform.Satisfies(*r.platform) {
+			return m.Digest, nil
+		}
+	}
+	log.Warnf("Image %q has no manifest for platform %s, using the digest of its index", image, r.platform)
+	return dsc.Digest, nil
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRegistryResolverPlatform(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	platformImage := func(p v1.Platform) v1.Image {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg = cfg.DeepCopy()
+		cfg.OS, cfg.Architecture = p.OS, p.Architecture
+		if img, err = mutate.ConfigFile(img, cfg); err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	digest := func(d interface{ Digest() (v1.Hash, error) }) string {
+		h, err := d.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.String()
+	}
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64"}
+
+	amd64Image, arm64Image := platformImage(amd64), platformImage(arm64)
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Image, Descriptor: v1.Descriptor{Platform: &amd64}},
+		mutate.IndexAddendum{Add: arm64Image, Descriptor: v1.Descriptor{Platform: &arm64}},
+	)
+	multi, single := host+"/multi:v1", host+"/single:v1"
+	for ref, push := range map[string]func(name.Reference) error{
+		multi:  func(r name.Reference) error { return remote.WriteIndex(r, index) },
+		single: func(r name.Reference) error { return remote.Write(r, amd64Image) },
+	} {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := push(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		image    string
+		platform string
+		want     string
+		warning  bool
+	}{
+		{multi, "", digest(index), false},
+		{multi, "linux/arm64", digest(arm64Image), false},
+		{multi, "linux/amd64", digest(amd64Image), false},
+		{multi, "linux/s390x", digest(index), true},
+		{single, "linux/amd64", digest(amd64Image), false},
+		{single, "linux/arm64", digest(amd64Image), true},
+	}
+	hook := logtest.NewGlobal()
+	for _, tc := range testCases {
+		var opts []RegistryResolverOption
+		if tc.platform != "" {
+			p, err := v1.ParsePlatform(tc.platform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts = append(opts, WithPlatform(p))
+		}
+		n, err := ParseImageName(tc.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := NewRegistryResolver(opts...).Resolve(&n); err != nil {
+			t.Fatalf("%s %s: %v", tc.image, tc.platform, err)
+		}
+		if n.Digest != tc.want {
+			t.Errorf("%s %s: got %s, want %s", tc.image, tc.platform, n.Digest, tc.want)
+		}
+		warned := false
+		for _, e := range hook.AllEntries() {
+			warned = warned || e.Level == logrus.WarnLevel
+		}
+		if warned != tc.warning {
+			t.Errorf("%s %s: warning logged: %v, want %v", tc.image, tc.platform, warned, tc.warning)
+		}
+		hook.Reset()
+	}
+}
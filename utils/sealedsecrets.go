@@ -0,0 +1,212 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The scopes of a SealedSecret, which restrict the names and namespaces of the Secret
+// the controller unseals it as.
+const (
+	SealedSecretStrict        = "strict"
+	SealedSecretNamespaceWide = "namespace-wide"
+	SealedSecretClusterWide   = "cluster-wide"
+)
+
+const (
+	sealedSecretNamespaceWideAnnotation = "sealedsecrets.bitnami.com/namespace-wide"
+	sealedSecretClusterWideAnnotation   = "sealedsecrets.bitnami.com/cluster-wide"
+)
+
+// SealSecret returns a bitnami.com/v1alpha1 SealedSecret holding the data of secret, encrypted
+// with the public key of the sealed-secrets controller certificate certPEM, like kubeseal does.
+// The scope is one of SealedSecretStrict, SealedSecretNamespaceWide or SealedSecretClusterWide.
+//
+// The data is encrypted with a random session key, so the result differs every time.
+func SealSecret(secret map[string]interface{}, certPEM string, scope string) (map[string]interface{}, error) {
+	if kind, _ := secret["kind"].(string); kind != "Secret" {
+		return nil, fmt.Errorf("expected a Secret, got kind %q", kind)
+	}
+	pub, err := parseSealedSecretsCert(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	secret = runtime.DeepCopyJSON(secret)
+	metadata, _ := secret["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+
+	var label string
+	annotations := map[string]interface{}{}
+	switch scope {
+	case SealedSecretStrict:
+		if name == "" || namespace == "" {
+			return nil, fmt.Errorf("a %s SealedSecret needs the name and namespace of the Secret", scope)
+		}
+		label = namespace + "/" + name
+	case SealedSecretNamespaceWide:
+		if namespace == "" {
+			return nil, fmt.Errorf("a %s SealedSecret needs the namespace of the Secret", scope)
+		}
+		label = namespace
+		annotations[sealedSecretNamespaceWideAnnotation] = "true"
+	case SealedSecretClusterWide:
+		annotations[sealedSecretClusterWideAnnotation] = "true"
+	default:
+		return nil, fmt.Errorf("unknown scope %q, expected %q, %q or %q", scope, SealedSecretStrict, SealedSecretNamespaceWide, SealedSecretClusterWide)
+	}
+
+	plaintexts := map[string][]byte{}
+	if data, ok := secret["data"].(map[string]interface{}); ok {
+		for k, v := range data {
+			s, _ := v.(string)
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("data.%s is not base64 encoded: %w", k, err)
+			}
+			plaintexts[k] = b
+		}
+	}
+	if stringData, ok := secret["stringData"].(map[string]interface{}); ok {
+		for k, v := range stringData {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("stringData.%s is not a string", k)
+			}
+			plaintexts[k] = []byte(s)
+		}
+	}
+	encryptedData := map[string]interface{}{}
+	for k, plaintext := range plaintexts {
+		ciphertext, err := hybridEncrypt(rand.Reader, pub, plaintext, []byte(label))
+		if err != nil {
+			return nil, fmt.Errorf("encrypting %s: %w", k, err)
+		}
+		encryptedData[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	// The template holds the metadata of the Secret, without the fields that would leak
+	// its data or that belong to the live object.
+	templateMetadata := metadata
+	if a, ok := templateMetadata["annotations"].(map[string]interface{}); ok {
+		delete(a, "kubectl.kubernetes.io/last-applied-configuration")
+		delete(a, "kubecfg.ksonnet.io/last-applied-configuration")
+	}
+	for _, k := range []string{"ownerReferences", "managedFields", "resourceVersion", "uid", "creationTimestamp"} {
+		delete(templateMetadata, k)
+	}
+	if len(annotations) > 0 {
+		a, _ := templateMetadata["annotations"].(map[string]interface{})
+		if a == nil {
+			a = map[string]interface{}{}
+			templateMetadata["annotations"] = a
+		}
+		for k, v := range annotations {
+			a[k] = v
+		}
+	}
+	template := map[string]interface{}{"metadata": templateMetadata}
+	for _, k := range []string{"type", "immutable"} {
+		if v, ok := secret[k]; ok {
+			template[k] = v
+		}
+	}
+
+	sealedMetadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		sealedMetadata["namespace"] = namespace
+	}
+	if len(annotations) > 0 {
+		sealedMetadata["annotations"] = annotations
+	}
+	return map[string]interface{}{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata":   sealedMetadata,
+		"spec": map[string]interface{}{
+			"encryptedData": encryptedData,
+			"template":      template,
+		},
+	}, nil
+}
+
+func parseSealedSecretsCert(certPEM string) (*rsa.PublicKey, error) {
+	rest := []byte(certPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM encoded certificate found")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("expected an RSA certificate, got a %T key", cert.PublicKey)
+		}
+		return pub, nil
+	}
+}
+
+// hybridEncrypt encrypts plaintext like the sealed-secrets controller expects: a random session
+// key encrypted with RSA-OAEP, with label, and its 2 bytes length, followed by plaintext encrypted
+// with the session key with AES-GCM.
+func hybridEncrypt(rnd io.Reader, pub *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, 32)
+	if _, err := io.ReadFull(rnd, sessionKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), rnd, pub, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+
+	res := binary.BigEndian.AppendUint16(nil, uint16(len(rsaCiphertext)))
+	res = append(res, rsaCiphertext...)
+	// The session key is used once, so the nonce can be zero.
+	zeroNonce := make([]byte, gcm.NonceSize())
+	return gcm.Seal(res, zeroNonce, plaintext, nil), nil
+}
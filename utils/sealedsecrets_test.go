@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-jsonnet"
+)
+
+// hybridDecrypt decrypts like the sealed-secrets controller does.
+func hybridDecrypt(priv *rsa.PrivateKey, ciphertext, label []byte) ([]byte, error) {
+	if len(ciphertext) < 2 {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	n := int(binary.BigEndian.Uint16(ciphertext))
+	if len(ciphertext) < 2+n {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, priv, ciphertext[2:2+n], label)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, make([]byte, gcm.NonceSize()), ciphertext[2+n:], nil)
+}
+
+func TestSealSecret(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seal := func(scope string) (map[string]interface{}, error) {
+		vm := jsonnet.MakeVM()
+		RegisterNativeFuncs(vm, NewIdentityResolver())
+		out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", fmt.Sprintf(`
+			std.native('sealSecret')({
+				apiVersion: 'v1',
+				kind: 'Secret',
+				metadata: {
+					name: 'db',
+					namespace: 'prod',
+					labels: { app: 'db' },
+					annotations: { 'kubectl.kubernetes.io/last-applied-configuration': '{"data":"leak"}' },
+				},
+				type: 'Opaque',
+				data: { password: std.base64('hunter2') },
+				stringData: { user: 'admin' },
+			}, importstr %q, %q)`, certFile, scope))
+		if err != nil {
+			return nil, err
+		}
+		var res map[string]interface{}
+		return res, json.Unmarshal([]byte(out), &res)
+	}
+
+	testCases := []struct {
+		scope       string
+		label       string
+		annotations map[string]interface{}
+	}{
+		{SealedSecretStrict, "prod/db", nil},
+		{SealedSecretNamespaceWide, "prod", map[string]interface{}{sealedSecretNamespaceWideAnnotation: "true"}},
+		{SealedSecretClusterWide, "", map[string]interface{}{sealedSecretClusterWideAnnotation: "true"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.scope, func(t *testing.T) {
+			sealed, err := seal(tc.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sealed["apiVersion"] != "bitnami.com/v1alpha1" || sealed["kind"] != "SealedSecret" {
+				t.Errorf("unexpected type %v/%v", sealed["apiVersion"], sealed["kind"])
+			}
+			metadata := sealed["metadata"].(map[string]interface{})
+			if metadata["name"] != "db" || metadata["namespace"] != "prod" {
+				t.Errorf("unexpected metadata %v", metadata)
+			}
+			if got, _ := metadata["annotations"].(map[string]interface{}); !reflect.DeepEqual(got, tc.annotations) {
+				t.Errorf("annotations: got %v, want %v", got, tc.annotations)
+			}
+
+			spec := sealed["spec"].(map[string]interface{})
+			template := spec["template"].(map[string]interface{})
+			if template["type"] != "Opaque" {
+				t.Errorf("template type: got %v", template["type"])
+			}
+			if s, _ := json.Marshal(template); strings.Contains(string(s), "leak") {
+				t.Errorf("template leaks the last applied configuration: %s", s)
+			}
+
+			encrypted := spec["encryptedData"].(map[string]interface{})
+			for key, want := range map[string]string{"password": "hunter2", "user": "admin"} {
+				ciphertext, err := base64.StdEncoding.DecodeString(encrypted[key].(string))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := hybridDecrypt(priv, ciphertext, []byte(tc.label))
+				if err != nil {
+					t.Fatalf("decrypting %s: %v", key, err)
+				}
+				if string(got) != want {
+					t.Errorf("%s: got %q, want %q", key, got, want)
+				}
+				if tc.label != "" {
+					if _, err := hybridDecrypt(priv, ciphertext, []byte("other/db")); err == nil {
+						t.Errorf("%s: decrypted with the label of another secret", key)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := seal("global"); err == nil || !strings.Contains(err.Error(), "unknown scope") {
+		t.Errorf("expected unknown scope error, got: %v", err)
+	}
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noNamespace := map[string]interface{}{"kind": "Secret", "metadata": map[string]interface{}{"name": "db"}}
+	if _, err := SealSecret(noNamespace, string(certPEM), SealedSecretStrict); err == nil || !strings.Contains(err.Error(), "namespace") {
+		t.Errorf("expected missing namespace error, got: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// NewSemverResolver returns a resolver of images whose tag is a semver constraint, like
+// example.com/app:~1.4 or "example.com/app:>= 1.2, < 2". They resolve to the highest tag of the
+// repository matching the constraint, pinned by digest. Images with a plain tag are resolved by digest.
+// Wildcard constraints like 1.x or 1.4.x are valid tags, but are always treated as constraints.
+//
+// The matching tag and its digest are pinned in lockfile, if not nil, so that images are upgraded
+// only when the lockfile is updated. The options configure the resolution of digests.
+func NewSemverResolver(lockfile *Lockfile, opts ...RegistryResolverOption) Resolver {
+	inner := NewRegistryResolver(opts...).(*registryResolver)
+	return &semverResolver{
+		inner:    inner,
+		lockfile: lockfile,
+		cache:    map[string]string{},
+	}
+}
+
+type semverResolver struct {
+	inner    *registryResolver
+	lockfile *Lockfile
+	cache    map[string]string
+}
+
+// wildcardTagRE matches the constraints that are also valid tags.
+var wildcardTagRE = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)?\.[xX]$`)
+
+// isTagConstraint reports whether tag of repo is a semver constraint rather than a plain tag.
+func isTagConstraint(repo, tag string) bool {
+	if wildcardTagRE.MatchString(tag) {
+		return true
+	}
+	_, err := name.NewTag(repo + ":" + tag)
+	return err != nil
+}
+
+// ParseImage implements ImageParser. Unlike ParseImageName, it accepts images whose
+// tag is a semver constraint.
+func (r *semverResolver) ParseImage(image string) (ImageName, error) {
+	n, err := ParseImageName(image)
+	if err == nil {
+		return n, nil
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || i < strings.LastIndex(image, "/") {
+		return n, err
+	}
+	repo, rerr := name.NewRepository(image[:i])
+	if rerr != nil {
+		return n, err
+	}
+	tag := image[i+1:]
+	if _, cerr := semver.NewConstraint(tag); cerr != nil {
+		return n, fmt.Errorf("invalid tag constraint %q of %s: %w", tag, repo, cerr)
+	}
+	ret := ImageName{Registry: repo.RegistryStr(), Repository: repo.RepositoryStr(), Tag: tag}
+	if ret.Registry == name.DefaultRegistry {
+		ret.Registry = "docker.io"
+	}
+	return ret, nil
+}
+
+func (r *semverResolver) Resolve(n *ImageName) error {
+	if n.Digest != "" {
+		return nil
+	}
+	repo := n.Registry + "/" + n.Repository
+	if !isTagConstraint(repo, n.Tag) {
+		return r.inner.Resolve(n)
+	}
+	constraint, err := semver.NewConstraint(n.Tag)
+	if err != nil {
+		return fmt.Errorf("invalid tag constraint %q of %s: %w", n.Tag, repo, err)
+	}
+
+	key := n.String()
+	if r.inner.platform != nil {
+		key += "#" + r.inner.platform.String()
+	}
+	pinned, ok := r.cache[key]
+	if !ok {
+		pinned, err = r.lockfile.PinTag(key, func() (string, error) {
+			tag, err := highestTag(repo, constraint)
+			if err != nil {
+				return "", err
+			}
+			resolved := ImageName{Registry: n.Registry, Repository: n.Repository, Tag: tag}
+			if err := r.inner.Resolve(&resolved); err != nil {
+				return "", err
+			}
+			return tag + "@" + resolved.Digest, nil
+		})
+		if err != nil {
+			return err
+		}
+		r.cache[key] = pinned
+	}
+
+	tag, digest, ok := strings.Cut(pinned, "@")
+	if !ok {
+		return fmt.Errorf("invalid pin %q of %s", pinned, key)
+	}
+	n.Tag, n.Digest = tag, digest
+	return nil
+}
+
+// highestTag returns the highest tag of the repository matching constraint.
+// Tags that are not semantic versions are ignored.
+func highestTag(repo string, constraint *semver.Constraints) (string, error) {
+	// TODO: get context from caller.
+	ctx := context.Background()
+
+	r, err := name.NewRepository(repo)
+	if err != nil {
+		return "", err
+	}
+	tags, err := remote.List(r, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", fmt.Errorf("listing tags of %s: %w", repo, err)
+	}
+
+	var (
+		best    *semver.Version
+		bestTag string
+	)
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best, bestTag = v, tag
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no tag of %s matches %q", repo, constraint)
+	}
+	return bestTag, nil
+}
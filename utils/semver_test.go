@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestSemverResolver(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/app"
+
+	digests := map[string]string{}
+	push := func(tag string) {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := name.ParseReference(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests[tag] = d.String()
+	}
+	for _, tag := range []string{"1.3.0", "1.4.0", "v1.4.7", "1.4.8-rc.1", "1.5.0", "latest"} {
+		push(tag)
+	}
+
+	path := filepath.Join(t.TempDir(), DefaultLockfileName)
+	resolve := func(mode LockMode, image string) (string, error) {
+		lockfile, err := LoadLockfile(path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := resolveImage(NewSemverResolver(lockfile), image)
+		if err != nil {
+			return "", err
+		}
+		return res, lockfile.Save()
+	}
+
+	testCases := []struct {
+		image string
+		want  string
+	}{
+		{repo + ":~1.4", repo + "@" + digests["v1.4.7"]},
+		{repo + ":>= 1.3, < 1.5", repo + "@" + digests["v1.4.7"]},
+		{repo + ":^1", repo + "@" + digests["1.5.0"]},
+		{repo + ":1.x", repo + "@" + digests["1.5.0"]},
+		{repo + ":1.4.x", repo + "@" + digests["v1.4.7"]},
+		{repo + ":1.4.0", repo + "@" + digests["1.4.0"]},
+		{repo + ":latest", repo + "@" + digests["latest"]},
+	}
+	for _, tc := range testCases {
+		got, err := resolve(LockUpdate, tc.image)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.image, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.image, got, tc.want)
+		}
+	}
+
+	if _, err := resolve(LockUpdate, repo+":~2.0"); err == nil || !strings.Contains(err.Error(), "no tag") {
+		t.Errorf("expected no matching tag error, got: %v", err)
+	}
+	if _, err := resolve(LockUpdate, repo+":~one"); err == nil || !strings.Contains(err.Error(), "invalid tag constraint") {
+		t.Errorf("expected invalid constraint error, got: %v", err)
+	}
+
+	// Only the semver resolver accepts constraints.
+	for _, r := range []Resolver{NewIdentityResolver(), NewRegistryResolver()} {
+		if _, err := resolveImage(r, repo+":~1.4"); err == nil {
+			t.Errorf("%T: expected error for tag constraint", r)
+		}
+	}
+
+	// New releases are picked only when the lockfile is updated.
+	push("1.4.9")
+	if got, err := resolve(LockEnforce, repo+":~1.4"); err != nil || got != repo+"@"+digests["v1.4.7"] {
+		t.Errorf("pinned tag: got %q, %v", got, err)
+	}
+	if got, err := resolve(LockUpdate, repo+":~1.4"); err != nil || got != repo+"@"+digests["1.4.9"] {
+		t.Errorf("updated tag: got %q, %v", got, err)
+	}
+}
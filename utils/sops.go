@@ -0,0 +1,465 @@
+// Copyright 2023 The kubecfg authors
+//
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	gohash "hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SopsAgeKeyFileEnv and SopsAgeKeyEnv are the environment variables sops reads age identities from.
+	SopsAgeKeyFileEnv = "SOPS_AGE_KEY_FILE"
+	SopsAgeKeyEnv     = "SOPS_AGE_KEY"
+
+	sopsMetadataKey = "sops"
+)
+
+// sopsMACOnlyEncryptedInit is hashed first by sops when only the encrypted values are part of the MAC,
+// so that the MAC differs from the one of all the values.
+var sopsMACOnlyEncryptedInit = []byte{
+	0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0x0b,
+	0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69,
+}
+
+// sopsValueRe matches the values encrypted by sops.
+var sopsValueRe = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// SopsKeys locates the private keys decrypting sops files.
+type SopsKeys struct {
+	// AgeKeyFiles contain age identities. If empty, the identities are read like sops does:
+	// from $SOPS_AGE_KEY, and from $SOPS_AGE_KEY_FILE or else sops/age/keys.txt in the user config directory.
+	AgeKeyFiles []string
+	// PGPKeyFiles contain PGP secret keys, armored or binary, not protected by a passphrase.
+	PGPKeyFiles []string
+}
+
+type sopsKeyGroup struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	PGP []struct {
+		Fingerprint string `yaml:"fp"`
+		Enc         string `yaml:"enc"`
+	} `yaml:"pgp"`
+}
+
+type sopsMetadata struct {
+	sopsKeyGroup     `yaml:",inline"`
+	KeyGroups        []sopsKeyGroup `yaml:"key_groups"`
+	LastModified     string         `yaml:"lastmodified"`
+	MAC              string         `yaml:"mac"`
+	MACOnlyEncrypted bool           `yaml:"mac_only_encrypted"`
+}
+
+// DecryptSops decrypts a YAML or JSON document encrypted by sops with age or PGP keys and returns
+// its content, without the sops metadata. The integrity of the document is checked against its MAC.
+func DecryptSops(data []byte, keys SopsKeys) (interface{}, error) {
+	var doc yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing sops file: %w", err)
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err != io.EOF {
+		return nil, fmt.Errorf("sops files with multiple documents are not supported")
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("sops file must contain a mapping")
+	}
+	root := doc.Content[0]
+
+	var metadata *sopsMetadata
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == sopsMetadataKey {
+			metadata = &sopsMetadata{}
+			if err := root.Content[i+1].Decode(metadata); err != nil {
+				return nil, fmt.Errorf("invalid sops metadata: %w", err)
+			}
+		}
+	}
+	if metadata == nil {
+		return nil, fmt.Errorf("not a sops encrypted file: %q key not found", sopsMetadataKey)
+	}
+
+	dataKey, err := sopsDataKey(metadata, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	d := sopsDecrypter{key: dataKey, hash: sha512.New(), macOnlyEncrypted: metadata.MACOnlyEncrypted}
+	if d.macOnlyEncrypted {
+		d.hash.Write(sopsMACOnlyEncryptedInit)
+	}
+	if err := d.comments(doc.HeadComment, nil); err != nil {
+		return nil, err
+	}
+	res, err := d.mapping(root, nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.comments(doc.FootComment, nil); err != nil {
+		return nil, err
+	}
+
+	if metadata.MAC == "" {
+		return nil, fmt.Errorf("sops file has no MAC")
+	}
+	mac, err := d.decrypt(metadata.MAC, metadata.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting MAC: %w", err)
+	}
+	macStr, _ := mac.(string)
+	if computed := fmt.Sprintf("%X", d.hash.Sum(nil)); subtle.ConstantTimeCompare([]byte(macStr), []byte(computed)) != 1 {
+		return nil, fmt.Errorf("sops file has been tampered with: MAC mismatch")
+	}
+	return res, nil
+}
+
+// sopsDataKey decrypts the key the values are encrypted with.
+func sopsDataKey(metadata *sopsMetadata, keys SopsKeys) ([]byte, error) {
+	group := metadata.sopsKeyGroup
+	switch len(metadata.KeyGroups) {
+	case 0:
+	case 1:
+		group = metadata.KeyGroups[0]
+	default:
+		return nil, fmt.Errorf("sops files with multiple key groups (Shamir secret sharing) are not supported")
+	}
+	if len(group.Age) == 0 && len(group.PGP) == 0 {
+		return nil, fmt.Errorf("sops file is not encrypted with age or PGP keys")
+	}
+
+	var errs []error
+	if len(group.Age) > 0 {
+		identities, err := keys.ageIdentities()
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range group.Age {
+			if len(identities) == 0 {
+				break
+			}
+			r, err := age.Decrypt(agearmor.NewReader(strings.NewReader(k.Enc)), identities...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("age recipient %s: %w", k.Recipient, err))
+				continue
+			}
+			return io.ReadAll(r)
+		}
+	}
+	if len(group.PGP) > 0 {
+		keyring, err := keys.pgpKeyring()
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range group.PGP {
+			if len(keyring) == 0 {
+				break
+			}
+			key, err := decryptPGP(k.Enc, keyring)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("PGP key %s: %w", k.Fingerprint, err))
+				continue
+			}
+			return key, nil
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no age or PGP private key available to decrypt the sops file")
+	}
+	return nil, fmt.Errorf("cannot decrypt the sops data key with the available age and PGP keys: %w", errors.Join(errs...))
+}
+
+func decryptPGP(enc string, keyring openpgp.EntityList) ([]byte, error) {
+	block, err := pgparmor.Decode(strings.NewReader(enc))
+	if err != nil {
+		return nil, err
+	}
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(md.UnverifiedBody)
+}
+
+func (k SopsKeys) ageIdentities() ([]age.Identity, error) {
+	files := k.AgeKeyFiles
+	var identities []age.Identity
+	if len(files) == 0 {
+		if s := os.Getenv(SopsAgeKeyEnv); s != "" {
+			ids, err := age.ParseIdentities(strings.NewReader(s))
+			if err != nil {
+				return nil, fmt.Errorf("parsing $%s: %w", SopsAgeKeyEnv, err)
+			}
+			identities = append(identities, ids...)
+		}
+		if f := os.Getenv(SopsAgeKeyFileEnv); f != "" {
+			files = []string{f}
+		} else if dir, err := os.UserConfigDir(); err == nil {
+			if f := filepath.Join(dir, "sops", "age", "keys.txt"); fileExists(f) {
+				files = []string{f}
+			}
+		}
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := age.ParseIdentities(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("parsing age identities in %s: %w", f, err)
+		}
+		identities = append(identities, ids...)
+	}
+	return identities, nil
+}
+
+func (k SopsKeys) pgpKeyring() (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	for _, f := range k.PGPKeyFiles {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+		if err != nil {
+			entities, err = openpgp.ReadKeyRing(bytes.NewReader(b))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing PGP keys in %s: %w", f, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// sopsDecrypter decrypts the values of a sops document, hashing them in the
+// same order as sops does to compute the MAC.
+type sopsDecrypter struct {
+	key              []byte
+	hash             gohash.Hash
+	macOnlyEncrypted bool
+}
+
+// sopsComment is a decrypted comment, which is hashed but not returned.
+type sopsComment string
+
+func (d *sopsDecrypter) comments(comments string, path []string) error {
+	for _, line := range strings.Split(comments, "\n") {
+		if line == "" {
+			continue
+		}
+		if _, err := d.leaf(sopsComment(line[1:]), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *sopsDecrypter) node(n *yaml.Node, path []string, commentsHandled bool) (interface{}, error) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return d.mapping(n, path, commentsHandled, false)
+	case yaml.SequenceNode:
+		if !commentsHandled {
+			if err := d.comments(n.HeadComment+"\n"+n.LineComment, path); err != nil {
+				return nil, err
+			}
+		}
+		res := []interface{}{}
+		for _, item := range n.Content {
+			if err := d.comments(item.HeadComment+"\n"+item.LineComment, path); err != nil {
+				return nil, err
+			}
+			v, err := d.node(item, path, true)
+			if err != nil {
+				return nil, err
+			}
+			// sops stores the comments of sequences as encrypted items.
+			if _, ok := v.(sopsComment); !ok {
+				res = append(res, v)
+			}
+			if err := d.comments(item.FootComment, path); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return d.leaf(v, path)
+	case yaml.AliasNode:
+		return d.node(n.Alias, path, false)
+	}
+	return nil, fmt.Errorf("unexpected YAML node kind %d", n.Kind)
+}
+
+func (d *sopsDecrypter) mapping(n *yaml.Node, path []string, commentsHandled, root bool) (map[string]interface{}, error) {
+	if !commentsHandled {
+		if err := d.comments(n.HeadComment+"\n"+n.LineComment, path); err != nil {
+			return nil, err
+		}
+	}
+	res := map[string]interface{}{}
+	for i := 0; i < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if err := d.comments(key.HeadComment+"\n"+key.LineComment, path); err != nil {
+			return nil, err
+		}
+		scalar := value.Kind == yaml.ScalarNode || value.Kind == yaml.AliasNode
+		if scalar {
+			if err := d.comments(value.HeadComment+"\n"+value.LineComment, path); err != nil {
+				return nil, err
+			}
+		}
+		if !root || key.Value != sopsMetadataKey {
+			v, err := d.node(value, append(path[:len(path):len(path)], key.Value), scalar)
+			if err != nil {
+				return nil, err
+			}
+			res[key.Value] = v
+		}
+		if scalar {
+			if err := d.comments(value.FootComment, path); err != nil {
+				return nil, err
+			}
+		}
+		if err := d.comments(key.FootComment, path); err != nil {
+			return nil, err
+		}
+	}
+	if !commentsHandled {
+		if err := d.comments(n.FootComment, path); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// leaf decrypts v if it is encrypted, and hashes it.
+func (d *sopsDecrypter) leaf(v interface{}, path []string) (interface{}, error) {
+	s, _ := v.(string)
+	if c, ok := v.(sopsComment); ok {
+		s = string(c)
+	}
+	encrypted := sopsValueRe.MatchString(s)
+	if encrypted {
+		var err error
+		if v, err = d.decrypt(s, strings.Join(path, ":")+":"); err != nil {
+			return nil, fmt.Errorf("decrypting %s: %w", strings.Join(path, "."), err)
+		}
+	}
+	if !encrypted && d.macOnlyEncrypted {
+		return v, nil
+	}
+
+	// Like nulls, comments are not part of the MAC.
+	switch v := v.(type) {
+	case nil, sopsComment:
+	case string:
+		d.hash.Write([]byte(v))
+	case []byte:
+		d.hash.Write(v)
+	case int:
+		d.hash.Write([]byte(strconv.Itoa(v)))
+	case float64:
+		d.hash.Write([]byte(strconv.FormatFloat(v, 'f', -1, 64)))
+	case bool:
+		if v {
+			d.hash.Write([]byte("True"))
+		} else {
+			d.hash.Write([]byte("False"))
+		}
+	default:
+		d.hash.Write([]byte(fmt.Sprint(v)))
+	}
+	return v, nil
+}
+
+// decrypt decrypts a value in the ENC[AES256_GCM,...] format.
+func (d *sopsDecrypter) decrypt(value, additionalData string) (interface{}, error) {
+	m := sopsValueRe.FindStringSubmatch(value)
+	if m == nil {
+		return nil, fmt.Errorf("invalid encrypted value")
+	}
+	var parts [3][]byte
+	for i, s := range m[1:4] {
+		var err error
+		if parts[i], err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("invalid encrypted value: %w", err)
+		}
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(d.key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("wrong data key or tampered value")
+	}
+
+	switch typ := m[4]; typ {
+	case "str":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	case "bytes":
+		return plaintext, nil
+	case "comment":
+		return sopsComment(plaintext), nil
+	default:
+		return nil, fmt.Errorf("unknown encrypted value type %q", typ)
+	}
+}
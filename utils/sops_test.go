@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/google/go-jsonnet"
+)
+
+// The files in testdata/sops were encrypted by sops 3.9.0, from a YAML document with comments and
+// its JSON equivalent, with the test keys next to them:
+//
+//	sops encrypt --age <recipient of age-keys.txt> secret.yaml > secret.age.yaml
+//	sops encrypt --pgp <fingerprint of pgp-key.asc> secret.json > secret.pgp.json
+//
+// secret.age.partial.yaml was encrypted with encrypted_regex: ^(password|hosts)$ and
+// mac_only_encrypted: true in .sops.yaml.
+const sopsTestdata = "../testdata/sops"
+
+func TestDecryptSops(t *testing.T) {
+	ageKeyFile := filepath.Join(sopsTestdata, "age-keys.txt")
+	pgpKeyFile := filepath.Join(sopsTestdata, "pgp-key.asc")
+	ageKeys := SopsKeys{AgeKeyFiles: []string{ageKeyFile}}
+	pgpKeys := SopsKeys{PGPKeyFiles: []string{pgpKeyFile}}
+
+	want := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"db"},"stringData":{"enabled":true,"hosts":["db-0.example.com","db-1.example.com"],"note_unencrypted":"public","password":"hunter2","ratio":0.5,"replicas":42}}`
+	testCases := []struct {
+		file string
+		keys SopsKeys
+	}{
+		{"secret.age.yaml", ageKeys},
+		{"secret.age.json", ageKeys},
+		{"secret.pgp.yaml", pgpKeys},
+		{"secret.pgp.json", pgpKeys},
+		{"secret.age.partial.yaml", ageKeys},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			vm := jsonnet.MakeVM()
+			RegisterNativeFuncs(vm, NewIdentityResolver(), WithSopsKeys(tc.keys))
+			file := filepath.Join(sopsTestdata, tc.file)
+			got, err := vm.EvaluateAnonymousSnippet("test.jsonnet", fmt.Sprintf(`std.manifestJsonMinified(std.native('decryptSops')(importstr %q))`, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != fmt.Sprintf("%q\n", want) {
+				t.Errorf("got: %s, want: %q", got, want)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(filepath.Join(sopsTestdata, "secret.age.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeyFile := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(otherKeyFile, []byte(other.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptSops(data, SopsKeys{AgeKeyFiles: []string{otherKeyFile}}); err == nil || !strings.Contains(err.Error(), "cannot decrypt the sops data key") {
+		t.Errorf("expected error with the wrong key, got: %v", err)
+	}
+	if _, err := DecryptSops(data, pgpKeys); err == nil {
+		t.Errorf("expected error without the age key")
+	}
+
+	tampered := bytes.Replace(data, []byte("note_unencrypted: public"), []byte("note_unencrypted: changed"), 1)
+	if _, err := DecryptSops(tampered, ageKeys); err == nil || !strings.Contains(err.Error(), "MAC mismatch") {
+		t.Errorf("expected MAC mismatch, got: %v", err)
+	}
+
+	t.Setenv(SopsAgeKeyFileEnv, ageKeyFile)
+	if _, err := DecryptSops(data, SopsKeys{}); err != nil {
+		t.Errorf("decrypting with $%s: %v", SopsAgeKeyFileEnv, err)
+	}
+}
@@ -33,46 +33,103 @@ var (
 	gkMutatingWebhook   = schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}
 )
 
-// a podSpecVisitor traverses a schema tree and records whether the schema
-// contains a PodSpec resource.
-type podSpecVisitor bool
+// a podSpecVisitor traverses a schema tree and records the paths of the
+// PodSpec resources it contains. Elements of arrays and maps are marked by "*".
+type podSpecVisitor struct {
+	path  []string
+	seen  map[string]bool // kinds on the current path, to stop at recursive schemas
+	paths [][]string
+}
 
 func (v *podSpecVisitor) VisitKind(k *proto.Kind) {
-	if k.GetPath().String() == "io.k8s.api.core.v1.PodSpec" {
-		*v = true
+	name := k.GetPath().String()
+	if name == "io.k8s.api.core.v1.PodSpec" {
+		v.paths = append(v.paths, append([]string(nil), v.path...))
 		return
 	}
-	for _, f := range k.Fields {
-		f.Accept(v)
-		if *v == true {
-			return
-		}
+	if v.seen[name] {
+		return
+	}
+	if v.seen == nil {
+		v.seen = map[string]bool{}
+	}
+	v.seen[name] = true
+	defer delete(v.seen, name)
+
+	fields := k.FieldOrder
+	if len(fields) == 0 {
+		fields = k.Keys()
 	}
+	for _, f := range fields {
+		v.visitChild(f, k.Fields[f])
+	}
+}
+
+func (v *podSpecVisitor) visitChild(step string, s proto.Schema) {
+	if s == nil {
+		return
+	}
+	v.path = append(v.path, step)
+	s.Accept(v)
+	v.path = v.path[:len(v.path)-1]
 }
 
 func (v *podSpecVisitor) VisitReference(s proto.Reference)  { s.SubSchema().Accept(v) }
-func (v *podSpecVisitor) VisitArray(s *proto.Array)         { s.SubType.Accept(v) }
-func (v *podSpecVisitor) VisitMap(s *proto.Map)             { s.SubType.Accept(v) }
+func (v *podSpecVisitor) VisitArray(s *proto.Array)         { v.visitChild("*", s.SubType) }
+func (v *podSpecVisitor) VisitMap(s *proto.Map)             { v.visitChild("*", s.SubType) }
 func (v *podSpecVisitor) VisitPrimitive(p *proto.Primitive) {}
 
-var podSpecCache = map[string]podSpecVisitor{}
+var podSpecCache = map[string][][]string{}
+
+// wellKnownPodSpecPaths are the paths of the PodSpecs of the built-in workload kinds,
+// which can be found without fetching the schema from the cluster.
+var wellKnownPodSpecPaths = map[schema.GroupKind][][]string{
+	{Kind: "Pod"}:                             {{"spec"}},
+	{Kind: "PodTemplate"}:                     {{"template", "spec"}},
+	{Kind: "ReplicationController"}:           {{"spec", "template", "spec"}},
+	{Group: "apps", Kind: "Deployment"}:       {{"spec", "template", "spec"}},
+	{Group: "apps", Kind: "ReplicaSet"}:       {{"spec", "template", "spec"}},
+	{Group: "apps", Kind: "StatefulSet"}:      {{"spec", "template", "spec"}},
+	{Group: "apps", Kind: "DaemonSet"}:        {{"spec", "template", "spec"}},
+	{Group: "batch", Kind: "Job"}:             {{"spec", "template", "spec"}},
+	{Group: "batch", Kind: "CronJob"}:         {{"spec", "jobTemplate", "spec", "template", "spec"}},
+	{Group: "extensions", Kind: "Deployment"}: {{"spec", "template", "spec"}},
+	{Group: "extensions", Kind: "ReplicaSet"}: {{"spec", "template", "spec"}},
+	{Group: "extensions", Kind: "DaemonSet"}:  {{"spec", "template", "spec"}},
+}
 
-func containsPodSpec(disco discovery.OpenAPISchemaInterface, gvk schema.GroupVersionKind) bool {
-	result, ok := podSpecCache[gvk.String()]
-	if ok {
-		return bool(result)
+// podSpecPaths returns the paths of the PodSpecs contained in objects of kind gvk.
+// The schema is fetched from disco, unless gvk is a built-in workload kind.
+func podSpecPaths(disco discovery.OpenAPISchemaInterface, gvk schema.GroupVersionKind) ([][]string, error) {
+	if paths, ok := wellKnownPodSpecPaths[gvk.GroupKind()]; ok {
+		return paths, nil
+	}
+	if paths, ok := podSpecCache[gvk.String()]; ok {
+		return paths, nil
+	}
+	if disco == nil {
+		return nil, nil
 	}
 
 	oapi, err := NewOpenAPISchemaFor(disco, gvk)
 	if err != nil {
-		log.Debugf("error fetching schema for %s: %v", gvk, err)
-		return false
+		return nil, err
 	}
 
-	oapi.schema.Accept(&result)
-	podSpecCache[gvk.String()] = result
+	var v podSpecVisitor
+	oapi.schema.Accept(&v)
+	podSpecCache[gvk.String()] = v.paths
+
+	return v.paths, nil
+}
 
-	return bool(result)
+func containsPodSpec(disco discovery.OpenAPISchemaInterface, gvk schema.GroupVersionKind) bool {
+	paths, err := podSpecPaths(disco, gvk)
+	if err != nil {
+		log.Debugf("error fetching schema for %s: %v", gvk, err)
+		return false
+	}
+	return len(paths) > 0
 }
 
 // Arbitrary numbers used to do a simple topological sort of resources.